@@ -2,24 +2,47 @@ package main
 
 import (
 	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/compiler"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/mikeyhu/glipso/interpreter"
 	"github.com/mikeyhu/glipso/parser"
 	"github.com/mikeyhu/glipso/prelude"
+	"github.com/mikeyhu/glipso/vm"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
+// evaluate evaluates each of the forms in turn with the vm, checking that the tree walking evaluator produces the same
+// results, and returns the result of the last
+func evaluate(t *testing.T, forms []interfaces.Type) (interfaces.Value, error) {
+	var result interfaces.Value = common.NILL
+	for _, form := range forms {
+		expected, expectedErr := common.Evaluate(form, common.GlobalEnvironment)
+		var err error
+		result, err = vm.Evaluate(form, common.GlobalEnvironment)
+		assert.Equal(t, expected, result, "tree walking evaluator disagrees with vm")
+		if expectedErr != nil {
+			assert.EqualError(t, err, expectedErr.Error(), "tree walking evaluator disagrees with vm")
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
 func Test_Acceptance_AddNumbers(t *testing.T) {
-	exp, err := parser.Parse("(+ 1 2 3 4 5)")
+	forms, err := parser.Parse("(+ 1 2 3 4 5)")
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(15), result)
 }
 
 func Test_Acceptance_ApplyAddNumbers(t *testing.T) {
-	exp, err := parser.Parse("(apply + (cons 1 (cons 2 (cons 3))))")
+	forms, err := parser.Parse("(apply + (cons 1 (cons 2 (cons 3))))")
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(6), result)
 }
@@ -28,9 +51,9 @@ func Test_Acceptance_IfEvaluatesSecondExpression(t *testing.T) {
 	code := `
 	(if (= 1 1) (+ 2 2) (+ 3 3))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(4), result)
 }
@@ -39,9 +62,9 @@ func Test_Acceptance_IfEvaluatesThirdExpression(t *testing.T) {
 	code := `
 	(if (= 1 2) (+ 2 2) (+ 3 3))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(6), result)
 }
@@ -53,17 +76,17 @@ func Test_Acceptance_CreatesAndUsesVariable(t *testing.T) {
 		(def two 2)
 		(+ one two))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(3), result)
 }
 
 func Test_Acceptance_SummingRange(t *testing.T) {
-	exp, err := parser.Parse("(apply + (range 1 5))")
+	forms, err := parser.Parse("(apply + (range 1 5))")
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(15), result)
 }
@@ -74,9 +97,9 @@ func Test_Acceptance_CreateAdd1Function(t *testing.T) {
 		(def add1 (fn [a] (+ 1 a)))
 		(add1 5))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(6), result)
 }
@@ -85,9 +108,9 @@ func Test_Acceptance_AnonymousAdd1Function(t *testing.T) {
 	code := `
 	((fn [a] (+ 1 a)) 5)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(6), result)
 }
@@ -99,9 +122,9 @@ func Test_Acceptance_EvenFunctionEvaluatesEvenNumber(t *testing.T) {
 		(even 2)
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(true), result)
 }
@@ -113,9 +136,9 @@ func Test_Acceptance_EvenFunctionEvaluatesOddNumber(t *testing.T) {
 		(even 1)
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(false), result)
 }
@@ -127,9 +150,9 @@ func Test_Acceptance_FilterEvenNumbers(t *testing.T) {
 		(apply + (filter even (cons 1 (cons 2 (cons 3 (cons 4))))))
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(6), result)
 }
@@ -141,9 +164,9 @@ func Test_Acceptance_MappingAdd1(t *testing.T) {
 		(first (map add1 (cons 1)))
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(2), result)
 }
@@ -155,9 +178,9 @@ func Test_Acceptance_LazyPairHasAccessToClosure(t *testing.T) {
 		(apply + (hasclosure 1 10))
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(11), result)
 }
@@ -179,9 +202,9 @@ func Test_Acceptance_LazyPairCanBeUsedToCreateRange(t *testing.T) {
 		)
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(true), result)
 }
@@ -189,9 +212,9 @@ func Test_Acceptance_LazyPairCanBeUsedToCreateRange(t *testing.T) {
 func Test_Acceptance_EmptyReturnsFalseWhenGivenAListWithContents(t *testing.T) {
 	code := `(empty (range 1 5))`
 
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(false), result)
 }
@@ -199,20 +222,29 @@ func Test_Acceptance_EmptyReturnsFalseWhenGivenAListWithContents(t *testing.T) {
 func Test_Acceptance_EmptyReturnsTrueWhenGivenAListWithNoContents(t *testing.T) {
 	code := `(empty (filter (fn [num] (> num 10)) (range 1 5)))`
 
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(true), result)
 }
 
+const rangefn = `
+	(def rangefn
+		(fn [s e]
+			(if (< s e)
+				(lazypair s (rangefn (+ s 1) e))
+				(cons s)
+			)
+		)
+	)`
+
 func BenchmarkSumRange(b *testing.B) {
 	code := "(apply + (range 1 15))"
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		exp, err := parser.Parse(code)
-		assert.NoError(b, err)
+		exp := parse(b, code)
 		result, err := exp.Evaluate(common.GlobalEnvironment)
 		assert.NoError(b, err)
 		assert.Equal(b, common.I(120), result)
@@ -221,33 +253,118 @@ func BenchmarkSumRange(b *testing.B) {
 
 func BenchmarkSumRangefn(b *testing.B) {
 	prelude.ParsePrelude(common.GlobalEnvironment)
-
-	fn := `
-	(do
-		(def rangefn
-			(fn [s e]
-				(if (< s e)
-					(lazypair s (rangefn (+ s 1) e))
-					(cons s)
-				)
-			)
-		)
-	)`
-
-	_, _ = parser.Parse(fn)
+	_, err := parse(b, rangefn).Evaluate(common.GlobalEnvironment)
+	assert.NoError(b, err)
 
 	code := "(apply + (rangefn 1 15))"
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		exp, err := parser.Parse(code)
-		assert.NoError(b, err)
+		exp := parse(b, code)
 		result, err := exp.Evaluate(common.GlobalEnvironment)
 		assert.NoError(b, err)
 		assert.Equal(b, common.I(120), result)
 	}
 }
 
+func BenchmarkSumRangeInterpreter(b *testing.B) {
+	benchmarkInterpreter(b, interpreter.New(), "(apply + (range 1 15))", common.I(120))
+}
+
+func BenchmarkSumRangefnInterpreter(b *testing.B) {
+	interp := interpreter.New()
+	_, err := interp.Eval(rangefn)
+	assert.NoError(b, err)
+	benchmarkInterpreter(b, interp, "(apply + (rangefn 1 15))", common.I(120))
+}
+
+func BenchmarkSumRangeCompiled(b *testing.B) {
+	benchmarkVM(b, "(apply + (range 1 15))", common.I(120))
+}
+
+func BenchmarkSumRangefnCompiled(b *testing.B) {
+	_, err := vm.Evaluate(parse(b, rangefn), common.GlobalEnvironment)
+	assert.NoError(b, err)
+	benchmarkVM(b, "(apply + (rangefn 1 15))", common.I(120))
+}
+
+func BenchmarkSumRangeTreeWalking(b *testing.B) {
+	benchmarkTreeWalking(b, "(apply + (range 1 15))", common.I(120))
+}
+
+func BenchmarkSumRangefnTreeWalking(b *testing.B) {
+	_, err := common.Evaluate(parse(b, rangefn), common.GlobalEnvironment)
+	assert.NoError(b, err)
+	benchmarkTreeWalking(b, "(apply + (rangefn 1 15))", common.I(120))
+}
+
+const brutePrimes = `
+(do
+	(defn notdivbyany [num listofdivs]
+		(empty (filter (fn [z] (= 0 z)) (map (fn [head] (% num head)) listofdivs))))
+	(defn getprimes [num listofprimes]
+		(if
+			(notdivbyany num listofprimes)
+			(lazypair num (getprimes (+ num 1) (cons num listofprimes)))
+			(getprimes (+ num 1) listofprimes))))`
+
+func BenchmarkBrutePrimes(b *testing.B) {
+	prelude.ParsePrelude(common.GlobalEnvironment)
+	_, err := vm.Evaluate(parse(b, brutePrimes), common.GlobalEnvironment)
+	assert.NoError(b, err)
+	benchmarkVM(b, "(apply + (take 50 (getprimes 3 (cons 2))))", common.I(5348))
+}
+
+func BenchmarkBrutePrimesTreeWalking(b *testing.B) {
+	prelude.ParsePrelude(common.GlobalEnvironment)
+	_, err := common.Evaluate(parse(b, brutePrimes), common.GlobalEnvironment)
+	assert.NoError(b, err)
+	benchmarkTreeWalking(b, "(apply + (take 50 (getprimes 3 (cons 2))))", common.I(5348))
+}
+
+func parse(b *testing.B, code string) *common.EXP {
+	forms, err := parser.Parse(code)
+	assert.NoError(b, err)
+	return forms[0].(*common.EXP)
+}
+
+// benchmarkInterpreter evaluates code with interp each time, parsing it as the original benchmarks do
+func benchmarkInterpreter(b *testing.B, interp *interpreter.Interpreter, code string, expected interfaces.Value) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := interp.Eval(code)
+		if err != nil || result != expected {
+			b.Fatalf("expected %v, got %v %v", expected, result, err)
+		}
+	}
+}
+
+// benchmarkVM compiles code once, running it each time
+func benchmarkVM(b *testing.B, code string, expected interfaces.Value) {
+	proto, err := compiler.Compile(parse(b, code), common.GlobalEnvironment)
+	assert.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := vm.Run(proto, common.GlobalEnvironment)
+		if err != nil || result != expected {
+			b.Fatalf("expected %v, got %v %v", expected, result, err)
+		}
+	}
+}
+
+func benchmarkTreeWalking(b *testing.B, code string, expected interfaces.Value) {
+	exp := common.Resolve(parse(b, code), common.GlobalEnvironment).(*common.EXP)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := exp.Evaluate(common.GlobalEnvironment)
+		if err != nil || result != expected {
+			b.Fatalf("expected %v, got %v %v", expected, result, err)
+		}
+	}
+}
+
 func BenchmarkParseCode(b *testing.B) {
 	code := `
 	(do
@@ -280,9 +397,9 @@ func Test_Acceptance_TakeReturnsFullListWhenSmallerThanTakeArgument(t *testing.T
 	prelude.ParsePrelude(common.GlobalEnvironment)
 	code := `(last (take 5 (range 1 3)))`
 
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(3), result)
 }
@@ -291,21 +408,21 @@ func Test_Acceptance_TakeReturnsPartialList(t *testing.T) {
 	prelude.ParsePrelude(common.GlobalEnvironment)
 	code := `(last (take 3 (range 1 5)))`
 
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(3), result)
 }
 
 func Test_Acceptance_ErrorsWhenFunctionNotFound(t *testing.T) {
 	code := `(notafunction 1)`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
 
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.Equal(t, common.NILL, result)
-	assert.EqualError(t, err, "evaluate : function 'notafunction' not found")
+	assert.EqualError(t, err, "1:2: evaluate : function 'notafunction' not found")
 
 }
 
@@ -318,27 +435,27 @@ func Test_Acceptance_ReturningRefReturnsCorrectlyScopedValue(t *testing.T) {
 		(hasA 1 2)
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(2), result)
 }
 
 func Test_Acceptance_LetAcceptsExpressionsInVectors(t *testing.T) {
 	code := `(let [a (+ 1 2)] (= 3 a))`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(true), result)
 }
 
 func Test_Acceptance_LetAcceptsValuesInVectors(t *testing.T) {
 	code := `(let [a 3] (= 3 a))`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.B(true), result)
 }
@@ -349,27 +466,27 @@ func Test_Acceptance_LetAcceptsMultipleValuesInVectors(t *testing.T) {
 		a 3
 		b 5
 		] (+ a b))`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.I(8), result)
 }
 
 func Test_Acceptance_AddFloatingPointNumbers(t *testing.T) {
 	code := `(+ 1.1 2.2 3.3)`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.F(6.6), result)
 }
 
 func Test_Acceptance_CombiningNumerics(t *testing.T) {
 	code := `(- (* 2 (+ 1 1.5)) 2)`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.F(3), result)
 }
@@ -380,9 +497,9 @@ func Test_Acceptance_MAPLookupWithSYM(t *testing.T) {
 		[m (hash-map :key "a value")]
 		(:key m)
 	)`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.S("a value"), result)
 }
@@ -393,9 +510,99 @@ func Test_Acceptance_MAPInitialisedWithMultipleKeys(t *testing.T) {
 		[m (hash-map :key "a value" :anotherKey "another value")]
 		(:anotherKey m)
 	)`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, err := exp.Evaluate(common.GlobalEnvironment)
+	result, err := evaluate(t, forms)
 	assert.NoError(t, err)
 	assert.Equal(t, common.S("another value"), result)
 }
+
+func Test_Acceptance_TailRecursiveCountdownRunsInConstantStack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 10 million iterations in short mode")
+	}
+	prelude.ParsePrelude(common.GlobalEnvironment)
+	code := `
+	(do
+		(defn countdown [n]
+			(if (= n 0)
+				"done"
+				(countdown (- n 1))))
+		(countdown 10000000)
+	)
+	`
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	result, err := evaluate(t, forms)
+	assert.NoError(t, err)
+	assert.Equal(t, common.S("done"), result)
+}
+
+func Test_Acceptance_MutuallyRecursiveTailCalls(t *testing.T) {
+	prelude.ParsePrelude(common.GlobalEnvironment)
+	code := `
+	(do
+		(defn is-even [n] (if (= n 0) true (is-odd (- n 1))))
+		(defn is-odd [n] (if (= n 0) false (is-even (- n 1))))
+		(is-even 100001)
+	)
+	`
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	result, err := evaluate(t, forms)
+	assert.NoError(t, err)
+	assert.Equal(t, common.B(false), result)
+}
+
+func Test_Acceptance_TailCallsThroughDoAndLet(t *testing.T) {
+	prelude.ParsePrelude(common.GlobalEnvironment)
+	code := `
+	(do
+		(defn sum-to [n acc]
+			(let [next (- n 1)]
+				(if (< n 1)
+					acc
+					(do
+						(sum-to next (+ acc n))))))
+		(sum-to 100000 0)
+	)
+	`
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	result, err := evaluate(t, forms)
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(5000050000), result)
+}
+
+func Test_Acceptance_LoopRecurIteratesInConstantSpace(t *testing.T) {
+	code := `
+	(loop [i 0 acc 0]
+		(if (< i 100000)
+			(recur (+ i 1) (+ acc i))
+			acc))
+	`
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	result, err := evaluate(t, forms)
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(4999950000), result)
+}
+
+func Test_Acceptance_LoopWithinFunctionUsesArguments(t *testing.T) {
+	prelude.ParsePrelude(common.GlobalEnvironment)
+	code := `
+	(do
+		(defn factorial [n]
+			(loop [i n acc 1]
+				(if (= i 0)
+					acc
+					(recur (- i 1) (* acc i)))))
+		(factorial 10)
+	)
+	`
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	result, err := evaluate(t, forms)
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(3628800), result)
+}
@@ -4,12 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mikeyhu/glipso/interfaces"
+	"strconv"
+	"strings"
 )
 
 // FN acts as storage for a reusable Appliable by storing a set of arguments to a function and the function expression itself
+// Scope is the scope the FN was created in, if it is nil the FN is evaluated within the scope it is applied from
+// Arities holds the FNs of a function with more than one arity, in which case it is applied by the one accepting the
+// number of arguments it is called with
 type FN struct {
 	Arguments  VEC
 	Expression interfaces.Evaluatable
+	Scope      interfaces.Scope
+	Arities    []FN
 }
 
 // IsType for FN
@@ -20,33 +27,160 @@ func (f FN) IsValue() {}
 
 // String output for FN
 func (f FN) String() string {
+	if len(f.Arities) > 0 {
+		arities := make([]string, len(f.Arities))
+		for i, arity := range f.Arities {
+			arities[i] = fmt.Sprintf("(%v, %v)", arity.Arguments, arity.Expression)
+		}
+		return fmt.Sprintf("FN(%v)", strings.Join(arities, " "))
+	}
 	return fmt.Sprintf("FN(%v, %v)", f.Arguments, f.Expression)
 }
 
+// Parameters returns the patterns bound by a VEC of parameters. When the last pattern follows & the parameters are
+// variadic, the last pattern being bound to a list of any arguments left over once the others have been bound.
+func Parameters(params VEC) ([]interfaces.Type, bool, error) {
+	patterns := make([]interfaces.Type, 0, len(params.Vector))
+	variadic := false
+	for i, param := range params.Vector {
+		if param == restMarker {
+			if i != len(params.Vector)-2 {
+				return nil, false, fmt.Errorf("expected a single parameter after &, recieved %v", params)
+			}
+			variadic = true
+			continue
+		}
+		if _, err := BoundNames(param); err != nil {
+			return nil, false, err
+		}
+		patterns = append(patterns, param)
+	}
+	return patterns, variadic, nil
+}
+
 // Apply for FN : validates the number of args and then applies the FN to the arguments
 func (f FN) Apply(arguments []interfaces.Type, env interfaces.Scope) (interfaces.Value, error) {
-	if len(f.Arguments.Vector) < len(arguments) {
+	env = Evaluating(env)
+	evaluation := EvaluationOf(env)
+	if err := evaluation.Enter(); err != nil {
+		return NILL, err
+	}
+	defer evaluation.Leave(1)
+	return trampoline(f.applyTail(arguments, env))
+}
+
+// applyTail binds the evaluated arguments to the patterns of its parameters in a new scope and returns the Expression as
+// a tailCall. Should the FN be variadic its last parameter is bound to a list of the arguments left over.
+func (f FN) applyTail(arguments []interfaces.Type, env interfaces.Scope) (interfaces.Value, error) {
+	if len(f.Arities) > 0 {
+		arity, err := f.arityFor(len(arguments))
+		if err != nil {
+			return NILL, err
+		}
+		return arity.applyTail(arguments, env)
+	}
+	params, variadic, err := Parameters(f.Arguments)
+	if err != nil {
+		return NILL, err
+	}
+	required := len(params)
+	if variadic {
+		required--
+	}
+	if !variadic && required < len(arguments) {
 		return NILL, errors.New("too many arguments")
-	} else if len(f.Arguments.Vector) > len(arguments) {
+	} else if required > len(arguments) {
 		return NILL, errors.New("too few arguments")
 	}
-	fnenv := env.NewChildScope()
-	for i, v := range f.Arguments.Vector {
-		eval, err := evaluateToValue(arguments[i], env)
-		if err != nil {
+	parent := f.Scope
+	if parent == nil {
+		parent = env
+	}
+	values := make([]interfaces.Value, len(arguments))
+	for i, arg := range arguments {
+		if values[i], err = evaluateToValue(arg, env); err != nil {
 			return NILL, err
 		}
-		fnenv.CreateRef(v.(REF), eval)
 	}
-	return f.Expression.Evaluate(fnenv)
+	fnenv := newLexicalScopeUnder(parent, EvaluationOf(env), len(params))
+	for i, param := range params[:required] {
+		if err := bind(param, values[i], fnenv, env); err != nil {
+			return NILL, err
+		}
+	}
+	if variadic {
+		EvaluationOf(env).Allocate(len(values) - required)
+		if err := bind(params[required], NewList(values[required:]), fnenv, env); err != nil {
+			return NILL, err
+		}
+	}
+	return &tailCall{f.Expression, fnenv}, nil
+}
+
+// arityOf returns the number of arguments an FN requires and whether it accepts more
+func arityOf(f FN) (int, bool, error) {
+	params, variadic, err := Parameters(f.Arguments)
+	if variadic {
+		return len(params) - 1, true, err
+	}
+	return len(params), false, err
+}
+
+// arityFor returns the arity of a multi-arity FN that accepts count arguments, preferring one that requires exactly
+// that number over one that is variadic
+func (f FN) arityFor(count int) (FN, error) {
+	var variadic *FN
+	accepted := make([]string, len(f.Arities))
+	for i, arity := range f.Arities {
+		required, more, err := arityOf(arity)
+		if err != nil {
+			return FN{}, err
+		}
+		if more {
+			accepted[i] = fmt.Sprintf("at least %d", required)
+			if count >= required {
+				variadic = &f.Arities[i]
+			}
+		} else {
+			accepted[i] = strconv.Itoa(required)
+			if count == required {
+				return arity, nil
+			}
+		}
+	}
+	if variadic != nil {
+		return *variadic, nil
+	}
+	return FN{}, fmt.Errorf("invalid number of arguments [%d], expected %v", count, joinAlternatives(accepted))
+}
+
+// joinAlternatives joins items as a list of alternatives, such as "1, 2 or 3"
+func joinAlternatives(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
 }
 
 // FI provides information about a built in function
+// host is true for functions provided by a program embedding Glipso, rather than by Glipso itself
 type FI struct {
 	name          string
 	evaluator     evaluator
 	lazyEvaluator lazyEvaluator
 	argumentCount int
+	host          bool
+}
+
+// NewFI creates an FI that applies evaluator to its arguments once they have been evaluated
+func NewFI(name string, evaluator func([]interfaces.Value, interfaces.Scope) (interfaces.Value, error)) FI {
+	return FI{name: name, evaluator: evaluator, host: true}
+}
+
+// NewLazyFI creates an FI that applies lazyEvaluator to its arguments without evaluating them, along with the Scope
+// they should be evaluated in. The arguments are as they were parsed, any REFs within them not having been resolved.
+func NewLazyFI(name string, lazyEvaluator func([]interfaces.Type, interfaces.Scope) (interfaces.Value, error)) FI {
+	return FI{name: name, lazyEvaluator: lazyEvaluator, host: true}
 }
 
 // IsType for FI
@@ -60,8 +194,40 @@ func (fi FI) String() string {
 	return fmt.Sprintf("FI(%s)", fi.name)
 }
 
+// Name of the FI
+func (fi FI) Name() string {
+	return fi.name
+}
+
+// Lazy returns true if the FI receives its arguments unevaluated
+func (fi FI) Lazy() bool {
+	return fi.lazyEvaluator != nil
+}
+
+// Host returns true if the FI was created by NewFI or NewLazyFI rather than being one of Glipso's own
+func (fi FI) Host() bool {
+	return fi.host
+}
+
+// ApplyValues applies an FI that is not Lazy to arguments that have already been evaluated, the arguments are not
+// retained so the caller may reuse them
+func (fi FI) ApplyValues(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+	if fi.argumentCount > 0 && len(arguments) != fi.argumentCount {
+		return NILL, fmt.Errorf("%v : invalid number of arguments [%d of %d]", fi.name, len(arguments), fi.argumentCount)
+	}
+	if fi.evaluator == nil {
+		return NILL, fmt.Errorf("FI : %v does not accept evaluated arguments", fi.name)
+	}
+	return fi.evaluator(arguments, sco)
+}
+
 // Apply for FI : validates the number of args and then applies the FI to the arguments
 func (fi FI) Apply(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	return trampoline(fi.applyTail(arguments, sco))
+}
+
+// applyTail applies the FI to the arguments, a lazyEvaluator may return a tailCall for an argument in tail position
+func (fi FI) applyTail(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
 	if fi.argumentCount > 0 && len(arguments) != fi.argumentCount {
 		return NILL, fmt.Errorf("%v : invalid number of arguments [%d of %d]", fi.name, len(arguments), fi.argumentCount)
 	}
@@ -76,6 +242,9 @@ func (fi FI) Apply(arguments []interfaces.Type, sco interfaces.Scope) (interface
 		}
 		return fi.evaluator(evaluatedArgs, sco)
 	} else if fi.lazyEvaluator != nil {
+		if fi.host {
+			return fi.lazyEvaluator(unresolveAll(arguments), sco)
+		}
 		unevaluatedArgs := make([]interfaces.Type, len(arguments))
 		copy(unevaluatedArgs, arguments)
 		return fi.lazyEvaluator(unevaluatedArgs, sco)
@@ -25,3 +25,81 @@ func Test_FI_Apply_ValidatesArgumentLengths(t *testing.T) {
 	assert.Equal(t, NILL, result)
 	assert.EqualError(t, err, "filter : invalid number of arguments [0 of 2]")
 }
+
+func Test_FN_Apply_BindsRemainingArgumentsToList(t *testing.T) {
+	//given
+	params := VEC{Vector: []interfaces.Type{REF("a"), REF("&"), REF("rest")}}
+	fn := FN{Arguments: params, Expression: EXPBuild(REF("cons")).withArgs(REF("a"), REF("rest")).build()}
+	//when
+	result, err := fn.Apply([]interfaces.Type{I(1), I(2), I(3)}, GlobalEnvironment.NewChildScope())
+	empty, emptyErr := fn.Apply([]interfaces.Type{I(1)}, GlobalEnvironment.NewChildScope())
+	_, tooFew := fn.Apply([]interfaces.Type{}, GlobalEnvironment.NewChildScope())
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{I(1), P{I(2), P{I(3), ENDED}}}, result)
+	assert.NoError(t, emptyErr)
+	assert.Equal(t, P{I(1), ENDED}, empty)
+	assert.EqualError(t, tooFew, "too few arguments")
+}
+
+func Test_FN_Apply_ClosesOverScopeItWasCreatedIn(t *testing.T) {
+	//given
+	created := newLexicalScope(GlobalEnvironment, 2)
+	created.CreateRef(REF("x"), I(1))
+	created.CreateRef(REF("y"), I(2))
+	caller := newLexicalScope(GlobalEnvironment, 1)
+	caller.CreateRef(REF("x"), I(10))
+	body := EXPBuild(REF("+")).withArgs(REF("x"), REF("y")).build()
+	fn := FN{Arguments: VEC{}, Expression: body, Scope: created}
+	//when
+	result, err := fn.Apply([]interfaces.Type{}, caller)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(3), result)
+}
+
+func Test_FN_Apply_WithoutScopeIsEvaluatedInScopeOfCaller(t *testing.T) {
+	//given
+	caller := newLexicalScope(GlobalEnvironment, 1)
+	caller.CreateRef(REF("x"), I(10))
+	body := EXPBuild(REF("+")).withArgs(REF("x"), REF("y")).build()
+	fn := FN{Arguments: VEC{Vector: []interfaces.Type{REF("y")}}, Expression: body}
+	//when
+	result, err := fn.Apply([]interfaces.Type{I(2)}, caller)
+	_, notFound := fn.Apply([]interfaces.Type{I(2)}, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(12), result)
+	assert.EqualError(t, notFound, "unable to resolve REF('x')")
+}
+
+func Test_Parameters_ErrorsWhenAmpersandIsNotFollowedByOneName(t *testing.T) {
+	for _, params := range [][]interfaces.Type{{REF("&")}, {REF("&"), REF("a"), REF("b")}, {REF("a"), I(1)}} {
+		//when
+		_, _, err := Parameters(VEC{Vector: params})
+		//then
+		assert.Error(t, err)
+	}
+}
+
+func Test_FN_MultiArityDispatchesOnNumberOfArguments(t *testing.T) {
+	//given
+	fn := FN{Arities: []FN{
+		{Arguments: VEC{Vector: []interfaces.Type{REF("a")}}, Expression: EXPBuild(REF("+")).withArgs(REF("a"), I(1)).build()},
+		{Arguments: VEC{Vector: []interfaces.Type{REF("a"), REF("b")}}, Expression: EXPBuild(REF("+")).withArgs(REF("a"), REF("b")).build()},
+		{Arguments: VEC{Vector: []interfaces.Type{REF("a"), REF("b"), REF("c"), REF("&"), REF("rest")}}, Expression: EXPBuild(REF("cons")).withArgs(REF("a"), REF("rest")).build()},
+	}}
+	//when
+	one, oneErr := fn.Apply([]interfaces.Type{I(1)}, GlobalEnvironment.NewChildScope())
+	two, twoErr := fn.Apply([]interfaces.Type{I(1), I(2)}, GlobalEnvironment.NewChildScope())
+	many, manyErr := fn.Apply([]interfaces.Type{I(1), I(2), I(3), I(4)}, GlobalEnvironment.NewChildScope())
+	_, noneErr := fn.Apply([]interfaces.Type{}, GlobalEnvironment.NewChildScope())
+	//then
+	assert.NoError(t, oneErr)
+	assert.Equal(t, I(2), one)
+	assert.NoError(t, twoErr)
+	assert.Equal(t, I(3), two)
+	assert.NoError(t, manyErr)
+	assert.Equal(t, P{I(1), P{I(4), ENDED}}, many)
+	assert.EqualError(t, noneErr, "invalid number of arguments [0], expected 1, 2 or at least 3")
+}
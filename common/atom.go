@@ -0,0 +1,165 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"sync"
+	"sync/atomic"
+)
+
+// ATOM holds a value that can be changed, safely from any goroutine. Changes are made by swapping in a new value only
+// if the value has not changed in the meantime, after which each watch is applied to the old and new values.
+type ATOM struct {
+	state   atomic.Pointer[atomState]
+	mutex   sync.Mutex
+	watches []watch
+}
+
+// atomState boxes the value of an ATOM so that it can be compared and swapped whatever its type
+type atomState struct {
+	value interfaces.Value
+}
+
+type watch struct {
+	key      interfaces.Value
+	function interfaces.Appliable
+}
+
+// NewATOM creates an ATOM holding value
+func NewATOM(value interfaces.Value) *ATOM {
+	a := &ATOM{}
+	a.state.Store(&atomState{value})
+	return a
+}
+
+// IsType for ATOM
+func (a *ATOM) IsType() {}
+
+// IsValue for ATOM
+func (a *ATOM) IsValue() {}
+
+// String representation of ATOM
+func (a *ATOM) String() string {
+	return fmt.Sprintf("ATOM(%v)", a.Deref())
+}
+
+// Deref returns the current value of the ATOM
+func (a *ATOM) Deref() interfaces.Value {
+	return a.state.Load().value
+}
+
+// swap sets the value of the ATOM to the result of update applied to its current value, returning the old and new
+// values. Should the value change while update is being applied then update is applied again to the new value.
+func (a *ATOM) swap(update func(interfaces.Value) (interfaces.Value, error)) (interfaces.Value, interfaces.Value, error) {
+	for {
+		old := a.state.Load()
+		value, err := update(old.value)
+		if err != nil {
+			return NILL, NILL, err
+		}
+		if a.state.CompareAndSwap(old, &atomState{value}) {
+			return old.value, value, nil
+		}
+	}
+}
+
+// addWatch adds a function to be applied whenever the value of the ATOM is changed, replacing any with the same key
+func (a *ATOM) addWatch(key interfaces.Value, function interfaces.Appliable) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for i, w := range a.watches {
+		if sameKey(w.key, key) {
+			a.watches[i].function = function
+			return
+		}
+	}
+	a.watches = append(a.watches, watch{key, function})
+}
+
+func sameKey(a interfaces.Value, b interfaces.Value) bool {
+	if equalable, ok := a.(interfaces.Equalable); ok {
+		if other, ok := b.(interfaces.Equalable); ok {
+			return equalable.Equals(other) == B(true)
+		}
+	}
+	return a == b
+}
+
+// notify applies each watch to its key, the ATOM and the old and new values
+func (a *ATOM) notify(old interfaces.Value, value interfaces.Value, sco interfaces.Scope) error {
+	a.mutex.Lock()
+	watches := make([]watch, len(a.watches))
+	copy(watches, a.watches)
+	a.mutex.Unlock()
+	for _, w := range watches {
+		call := &EXP{Function: w.function, Arguments: []interfaces.Type{w.key, a, old, value}}
+		if _, err := evaluateToValue(call, sco); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func atom(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+	return NewATOM(arguments[0]), nil
+}
+
+func deref(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+	a, ok := arguments[0].(*ATOM)
+	if !ok {
+		return NILL, fmt.Errorf("deref : expected ATOM, recieved %v", arguments[0])
+	}
+	return a.Deref(), nil
+}
+
+func reset(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+	a, ok := arguments[0].(*ATOM)
+	if !ok {
+		return NILL, fmt.Errorf("reset! : expected ATOM, recieved %v", arguments[0])
+	}
+	old := a.state.Swap(&atomState{arguments[1]})
+	return arguments[1], a.notify(old.value, arguments[1], sco)
+}
+
+// swap sets the value of an ATOM to the result of applying a function to its current value, followed by any other
+// arguments. The function may be applied more than once, should another goroutine change the value meanwhile.
+func swap(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+	if len(arguments) < 2 {
+		return NILL, fmt.Errorf("swap! : invalid number of arguments [%d of at least 2]", len(arguments))
+	}
+	a, ok := arguments[0].(*ATOM)
+	if !ok {
+		return NILL, fmt.Errorf("swap! : expected ATOM, recieved %v", arguments[0])
+	}
+	function, ok := arguments[1].(interfaces.Appliable)
+	if !ok {
+		return NILL, fmt.Errorf("swap! : expected function, recieved %v", arguments[1])
+	}
+	old, value, err := a.swap(func(current interfaces.Value) (interfaces.Value, error) {
+		args := make([]interfaces.Type, 0, len(arguments)-1)
+		args = append(args, current)
+		for _, arg := range arguments[2:] {
+			args = append(args, arg)
+		}
+		return evaluateToValue(&EXP{Function: function, Arguments: args}, sco)
+	})
+	if err != nil {
+		return NILL, err
+	}
+	return value, a.notify(old, value, sco)
+}
+
+// addWatch adds a function to an ATOM that is applied to the key, the ATOM and its old and new values whenever
+// reset! or swap! change it
+func addWatch(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+	a, ok := arguments[0].(*ATOM)
+	if !ok {
+		return NILL, fmt.Errorf("add-watch : expected ATOM, recieved %v", arguments[0])
+	}
+	function, ok := arguments[2].(interfaces.Appliable)
+	if !ok {
+		return NILL, fmt.Errorf("add-watch : expected function, recieved %v", arguments[2])
+	}
+	a.addWatch(arguments[1], function)
+	return a, nil
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func Test_swap_AppliesFunctionToValueAndArguments(t *testing.T) {
+	//given
+	a := NewATOM(I(1))
+	exp := EXPBuild(REF("swap!")).withArgs(a, REF("+"), I(2), I(3)).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(6), result)
+	assert.Equal(t, I(6), a.Deref())
+}
+
+func Test_swap_IsSafeAcrossGoroutines(t *testing.T) {
+	//given
+	a := NewATOM(I(0))
+	exp := EXPBuild(REF("swap!")).withArgs(a, REF("+"), I(1)).build()
+	var wg sync.WaitGroup
+	//when
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, err := exp.Evaluate(NewEnvironment())
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	//then
+	assert.Equal(t, I(800), a.Deref())
+}
+
+func Test_reset_AppliesWatchesToOldAndNewValues(t *testing.T) {
+	//given
+	a := NewATOM(I(1))
+	var seen []interfaces.Value
+	watcher := NewFI("watcher", func(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+		seen = arguments
+		return NILL, nil
+	})
+	_, err := EXPBuild(REF("add-watch")).withArgs(a, SYM(":w"), watcher).build().Evaluate(GlobalEnvironment)
+	assert.NoError(t, err)
+	//when
+	result, err := EXPBuild(REF("reset!")).withArgs(a, I(2)).build().Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(2), result)
+	assert.Equal(t, []interfaces.Value{SYM(":w"), a, I(1), I(2)}, seen)
+}
+
+func Test_deref_ExpectsATOM(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("deref")).withArgs(I(1)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "deref : expected ATOM, recieved 1")
+}
@@ -0,0 +1,379 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"reflect"
+	"strings"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	valueType = reflect.TypeOf((*interfaces.Value)(nil)).Elem()
+)
+
+// Bind makes a Go value available to code evaluated in the Environment as name. A function becomes a builtin whose
+// arguments and results are converted to and from the types in its signature, anything else is converted and
+// created as a variable. I, F, S, B, VEC, lists and MAPs convert to and from ints, floats, strings, bools, slices and
+// maps, SYMs convert to strings without their leading ':' and string keys of maps become SYMs. Pointers convert to
+// the value they point to.
+func (env *Environment) Bind(name string, value any) error {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Func {
+		if rv.IsNil() {
+			return fmt.Errorf("%v : expected a function, recieved nil %v", name, rv.Type())
+		}
+		fi, err := newReflectFI(name, rv)
+		if err != nil {
+			return err
+		}
+		env.AddInbuilt(fi)
+		return nil
+	}
+	converted, err := fromGo(rv)
+	if err != nil {
+		return fmt.Errorf("%v : %v", name, err)
+	}
+	env.CreateRef(REF(name), converted)
+	return nil
+}
+
+// newReflectFI creates an FI that applies fn, a function that may return a single result, an error, or a result
+// followed by an error
+func newReflectFI(name string, fn reflect.Value) (FI, error) {
+	t := fn.Type()
+	out := t.NumOut()
+	if out > 2 || out == 2 && t.Out(1) != errorType {
+		return FI{}, fmt.Errorf("%v : expected a function returning at most a result and an error, recieved %v", name, t)
+	}
+	return NewFI(name, func(args []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+		in := t.NumIn()
+		if t.IsVariadic() && len(args) < in-1 {
+			return NILL, fmt.Errorf("%v : invalid number of arguments [%d of at least %d]", name, len(args), in-1)
+		} else if !t.IsVariadic() && len(args) != in {
+			return NILL, fmt.Errorf("%v : invalid number of arguments [%d of %d]", name, len(args), in)
+		}
+		values := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var argType reflect.Type
+			if t.IsVariadic() && i >= in-1 {
+				argType = t.In(in - 1).Elem()
+			} else {
+				argType = t.In(i)
+			}
+			value, err := toGoType(arg, argType, sco)
+			if err != nil {
+				return NILL, fmt.Errorf("%v : argument %d %v", name, i+1, err)
+			}
+			values[i] = value
+		}
+		results, err := call(name, fn, values)
+		if err != nil {
+			return NILL, err
+		}
+		if len(results) > 0 && t.Out(len(results)-1) == errorType {
+			if err, _ := results[len(results)-1].Interface().(error); err != nil {
+				return NILL, fmt.Errorf("%v : %v", name, err)
+			}
+			results = results[:len(results)-1]
+		}
+		if len(results) == 0 {
+			return NILL, nil
+		}
+		result, err := fromGo(results[0])
+		if err != nil {
+			return NILL, fmt.Errorf("%v : result %v", name, err)
+		}
+		return result, nil
+	}), nil
+}
+
+// call applies fn to values, returning a panic raised by fn as an error rather than letting it stop the host program
+func call(name string, fn reflect.Value, values []reflect.Value) (results []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v : panicked with %v", name, r)
+		}
+	}()
+	return fn.Call(values), nil
+}
+
+// toGoType converts value to a Go value of type t. An interface that value already implements, such as
+// interfaces.Value, receives it unconverted, whereas an empty interface receives the Go value it naturally converts to.
+func toGoType(value interfaces.Value, t reflect.Type, sco interfaces.Scope) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface {
+		if t.NumMethod() > 0 && reflect.TypeOf(value).Implements(t) {
+			return reflect.ValueOf(value).Convert(t), nil
+		}
+		if t.NumMethod() == 0 {
+			converted, err := toGo(value, sco, DefaultLimit)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if converted == nil {
+				return reflect.Zero(t), nil
+			}
+			return reflect.ValueOf(converted).Convert(t), nil
+		}
+	}
+	if value == NILL {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+			return reflect.Zero(t), nil
+		}
+	}
+	result := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := value.(B); ok {
+			result.SetBool(bool(b))
+			return result, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := value.(I); ok {
+			if result.OverflowInt(int64(i)) {
+				return reflect.Value{}, fmt.Errorf("expected %v, recieved %v which is out of range", t, value)
+			}
+			result.SetInt(int64(i))
+			return result, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, ok := value.(I); ok {
+			if i < 0 || result.OverflowUint(uint64(i)) {
+				return reflect.Value{}, fmt.Errorf("expected %v, recieved %v which is out of range", t, value)
+			}
+			result.SetUint(uint64(i))
+			return result, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch f := value.(type) {
+		case F:
+			result.SetFloat(float64(f))
+			return result, nil
+		case I:
+			result.SetFloat(float64(f))
+			return result, nil
+		}
+	case reflect.String:
+		switch s := value.(type) {
+		case S:
+			result.SetString(string(s))
+			return result, nil
+		case SYM:
+			result.SetString(strings.TrimPrefix(string(s), ":"))
+			return result, nil
+		}
+	case reflect.Slice:
+		if items, ok, err := itemsOf(value, sco, DefaultLimit); ok {
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			result = reflect.MakeSlice(t, len(items), len(items))
+			for i, item := range items {
+				converted, err := toGoType(item, t.Elem(), sco)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("item %d %v", i+1, err)
+				}
+				result.Index(i).Set(converted)
+			}
+			return result, nil
+		}
+	case reflect.Map:
+		if mp, ok := value.(*MAP); ok {
+			result = reflect.MakeMap(t)
+			for k, v := range mp.entries() {
+				key, err := toGoType(k.(interfaces.Value), t.Key(), sco)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %v", err)
+				}
+				converted, err := toGoType(v, t.Elem(), sco)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value of %v %v", k, err)
+				}
+				result.SetMapIndex(key, converted)
+			}
+			return result, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("expected %v, recieved %v", t, value)
+}
+
+// DefaultLimit is the number of items that may be taken from a single list when converting it to a Go slice, it guards
+// against iterating through an infinite lazy list forever
+const DefaultLimit = 100000
+
+// ToGo converts value to the Go value it most naturally corresponds to: an int, float64, string, bool, []any for a VEC
+// or list, map[any]any for a MAP, or nil for NIL. SYMs become strings without their leading ':'. Lazy lists are
+// evaluated in sco, failing should any list have more than DefaultLimit items. Values with no Go counterpart, such as
+// functions, are returned unconverted.
+func ToGo(value interfaces.Value, sco interfaces.Scope) (any, error) {
+	return toGo(value, sco, DefaultLimit)
+}
+
+// ToGoLimit is ToGo, failing should any list have more than limit items
+func ToGoLimit(value interfaces.Value, sco interfaces.Scope, limit int) (any, error) {
+	return toGo(value, sco, limit)
+}
+
+// FromGo converts a Go value to a Glipso value. Values that are already Glipso values are returned as they are, nil
+// becomes NIL, pointers become the value they point to, slices and arrays become lists, and maps become MAPs with any
+// string keys converted to SYMs.
+func FromGo(value any) (interfaces.Value, error) {
+	return fromGo(reflect.ValueOf(value))
+}
+
+func toGo(value interfaces.Value, sco interfaces.Scope, limit int) (any, error) {
+	switch v := value.(type) {
+	case NIL:
+		return nil, nil
+	case B:
+		return bool(v), nil
+	case I:
+		return int(v), nil
+	case F:
+		return float64(v), nil
+	case S:
+		return string(v), nil
+	case SYM:
+		return strings.TrimPrefix(string(v), ":"), nil
+	case *MAP:
+		result := map[any]any{}
+		for k, item := range v.entries() {
+			key, err := toGo(k.(interfaces.Value), sco, limit)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, fmt.Errorf("unable to use %v as a key", k)
+			}
+			if result[key], err = toGo(item, sco, limit); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	if items, ok, err := itemsOf(value, sco, limit); ok {
+		if err != nil {
+			return nil, err
+		}
+		result := make([]any, len(items))
+		for i, item := range items {
+			if result[i], err = toGo(item, sco, limit); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// itemsOf returns the evaluated items of a VEC or list, or false if value is neither. It fails if there are more than
+// limit items.
+func itemsOf(value interfaces.Value, sco interfaces.Scope, limit int) ([]interfaces.Value, bool, error) {
+	var items []interfaces.Value
+	switch v := value.(type) {
+	case VEC:
+		if len(v.Vector) > limit {
+			return nil, true, fmt.Errorf("list has more than %d items", limit)
+		}
+		items = make([]interfaces.Value, len(v.Vector))
+		for i, item := range v.Vector {
+			evaluated, err := evaluateToValue(item, sco)
+			if err != nil {
+				return nil, true, err
+			}
+			items[i] = evaluated
+		}
+	case *MAP:
+		return nil, false, nil
+	case interfaces.Iterable:
+		for next := v; next != ENDED; {
+			if len(items) == limit {
+				return nil, true, fmt.Errorf("list has more than %d items", limit)
+			}
+			items = append(items, next.Head())
+			if !next.HasTail() {
+				break
+			}
+			var err error
+			if next, err = next.Iterate(sco); err != nil {
+				return nil, true, err
+			}
+		}
+	default:
+		return nil, false, nil
+	}
+	return items, true, nil
+}
+
+// fromGo converts the Go value held by rv, see FromGo
+func fromGo(rv reflect.Value) (interfaces.Value, error) {
+	if !rv.IsValid() {
+		return NILL, nil
+	}
+	if rv.Type().Implements(valueType) {
+		if rv.Kind() == reflect.Interface && rv.IsNil() {
+			return NILL, nil
+		}
+		return rv.Interface().(interfaces.Value), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return B(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return I(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > uint64(^uint(0)>>1) {
+			return NILL, fmt.Errorf("unable to convert %v which is out of range", rv)
+		}
+		return I(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return F(rv.Float()), nil
+	case reflect.String:
+		return S(rv.String()), nil
+	case reflect.Interface, reflect.Pointer:
+		if rv.IsNil() {
+			return NILL, nil
+		}
+		return fromGo(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		var list interfaces.Iterable = ENDED
+		for i := rv.Len() - 1; i >= 0; i-- {
+			item, err := fromGo(rv.Index(i))
+			if err != nil {
+				return NILL, err
+			}
+			list = P{item, list}
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return NILL, nil
+		}
+		arguments := make([]interfaces.Value, 0, rv.Len()*2)
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := fromGo(iter.Key())
+			if err != nil {
+				return NILL, err
+			}
+			if s, ok := key.(S); ok {
+				key = SYM(":" + string(s))
+			}
+			if _, ok := key.(interfaces.Equalable); !ok {
+				return NILL, fmt.Errorf("unable to use %v as a key", iter.Key())
+			}
+			value, err := fromGo(iter.Value())
+			if err != nil {
+				return NILL, err
+			}
+			arguments = append(arguments, key, value)
+		}
+		mp, err := initialiseMAP(arguments)
+		if err != nil {
+			return NILL, err
+		}
+		return mp, nil
+	}
+	return NILL, fmt.Errorf("unable to convert %v", rv.Type())
+}
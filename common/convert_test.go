@@ -0,0 +1,129 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func Test_toGoType_IntOutOfRange(t *testing.T) {
+	//when
+	_, err := toGoType(I(300), reflect.TypeOf(int8(0)), GlobalEnvironment)
+
+	//then
+	assert.EqualError(t, err, "expected int8, recieved 300 which is out of range")
+}
+
+func Test_toGoType_AssociatedMAP(t *testing.T) {
+	//given
+	m, _ := initialiseMAP([]interfaces.Value{SYM(":a"), I(1), SYM(":b"), I(2)})
+	m, _ = m.associate([]interfaces.Value{SYM(":b"), I(3)})
+
+	//when
+	result, err := toGoType(m, reflect.TypeOf(map[string]int{}), GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 3}, result.Interface())
+}
+
+func Test_toGoType_EmptyInterfaceReceivesGoValues(t *testing.T) {
+	//given
+	list := P{I(1), P{VEC{Vector: []interfaces.Type{S("a"), NILL}}, ENDED}}
+
+	//when
+	result, err := toGoType(list, reflect.TypeOf([]any{}), GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, []any{1, []any{"a", nil}}, result.Interface())
+}
+
+func Test_fromGo_SliceBecomesList(t *testing.T) {
+	//when
+	result, err := fromGo(reflect.ValueOf([]float32{1.5, 2}))
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{F(1.5), P{F(2), ENDED}}, result)
+}
+
+func Test_fromGo_PointerBecomesValuePointedTo(t *testing.T) {
+	//given
+	retries := 3
+	hosts := &[]string{"a"}
+	//when
+	result, err := fromGo(reflect.ValueOf(map[string]any{"retries": &retries, "hosts": &hosts, "none": (*int)(nil)}))
+	//then
+	assert.NoError(t, err)
+	mp := result.(*MAP)
+	retriesValue, _ := mp.lookup(SYM(":retries"))
+	hostsValue, _ := mp.lookup(SYM(":hosts"))
+	noneValue, _ := mp.lookup(SYM(":none"))
+	assert.Equal(t, I(3), retriesValue)
+	assert.Equal(t, P{S("a"), ENDED}, hostsValue)
+	assert.Equal(t, NILL, noneValue)
+}
+
+func Test_Bind_RejectsNilFunction(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	//when
+	err := env.Bind("f", (func(int) int)(nil))
+	//then
+	assert.EqualError(t, err, "f : expected a function, recieved nil func(int) int")
+	_, found := env.ResolveRef(REF("f"))
+	assert.False(t, found)
+}
+
+func Test_Bind_ReturnsPanicAsError(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	assert.NoError(t, env.Bind("explode", func(n int) int { panic(fmt.Sprintf("cannot handle %d", n)) }))
+	//when
+	_, err := Evaluate(EXPBuild(REF("explode")).withArgs(I(3)).build(), env)
+	//then
+	assert.EqualError(t, err, "explode : panicked with cannot handle 3")
+}
+
+func Test_ToGo_ConvertsNestedValues(t *testing.T) {
+	//given
+	m, _ := initialiseMAP([]interfaces.Value{SYM(":name"), S("glipso"), I(1), P{F(1.5), P{B(true), ENDED}}})
+
+	//when
+	result, err := ToGo(m, GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"name": "glipso", 1: []any{1.5, true}}, result)
+}
+
+func Test_ToGoLimit_FailsForLongLists(t *testing.T) {
+	//given
+	list, _ := EXPBuild(REF("range")).withArgs(I(1), I(10)).build().Evaluate(GlobalEnvironment)
+
+	//when
+	_, err := ToGoLimit(list, GlobalEnvironment, 5)
+	all, allErr := ToGoLimit(list, GlobalEnvironment, 10)
+
+	//then
+	assert.EqualError(t, err, "list has more than 5 items")
+	assert.NoError(t, allErr)
+	assert.Equal(t, []any{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, all)
+}
+
+func Test_FromGo_IsInverseOfToGo(t *testing.T) {
+	//given
+	original := map[any]any{"a": []any{1, 2.5, "b", nil, false}}
+
+	//when
+	value, err := FromGo(original)
+	result, toErr := ToGo(value, GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.NoError(t, toErr)
+	assert.Equal(t, original, result)
+}
@@ -0,0 +1,249 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"strings"
+)
+
+// Patterns are what let, loop, fn and macro bind values to. A REF binds the whole value. A VEC such as [a b & more]
+// binds the items of a list or VEC in turn, & binding a list of those left over, and {:keys [a b]} or {a :a} binds the
+// values of a MAP. Either may end with :as name to bind the whole value as well.
+
+const (
+	restMarker = REF("&")
+	asMarker   = SYM(":as")
+	keysMarker = SYM(":keys")
+)
+
+// BoundNames returns the names that a pattern binds, in the order that they are bound
+func BoundNames(pattern interfaces.Type) ([]REF, error) {
+	var names []REF
+	err := walkPattern(pattern, func(name REF) {
+		names = append(names, name)
+	})
+	return names, err
+}
+
+// walkPattern calls found with each name a pattern binds, in the order that bind binds them
+func walkPattern(pattern interfaces.Type, found func(REF)) error {
+	switch p := pattern.(type) {
+	case REF:
+		found(p)
+		return nil
+	case VEC:
+		items, rest, as, err := sequentialPattern(p)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := walkPattern(item, found); err != nil {
+				return err
+			}
+		}
+		if rest != nil {
+			if err := walkPattern(rest, found); err != nil {
+				return err
+			}
+		}
+		if as != "" {
+			found(as)
+		}
+		return nil
+	case *EXP:
+		if entries, as, err := mapPattern(p); err == nil {
+			for _, entry := range entries {
+				if err := walkPattern(entry.pattern, found); err != nil {
+					return err
+				}
+			}
+			if as != "" {
+				found(as)
+			}
+			return nil
+		} else if err != errNotMapPattern {
+			return err
+		}
+	}
+	return fmt.Errorf("unable to bind to %v, expected a name, VEC or map", patternString(pattern))
+}
+
+// sequentialPattern splits a VEC pattern into the patterns of its items, that of the rest following &, and the name
+// following :as
+func sequentialPattern(vec VEC) (items []interfaces.Type, rest interfaces.Type, as REF, err error) {
+	for i := 0; i < len(vec.Vector); i++ {
+		switch vec.Vector[i] {
+		case restMarker:
+			if rest != nil || as != "" || i+1 == len(vec.Vector) {
+				return nil, nil, "", fmt.Errorf("expected a single pattern after & in %v", patternString(vec))
+			}
+			i++
+			rest = vec.Vector[i]
+		case asMarker:
+			name, ok := itemAfter(vec.Vector, i).(REF)
+			if !ok || i+2 != len(vec.Vector) {
+				return nil, nil, "", fmt.Errorf("expected a name to end %v after :as", patternString(vec))
+			}
+			return items, rest, name, nil
+		default:
+			if rest != nil {
+				return nil, nil, "", fmt.Errorf("expected a single pattern after & in %v", patternString(vec))
+			}
+			items = append(items, vec.Vector[i])
+		}
+	}
+	return items, rest, "", nil
+}
+
+// mapEntry binds pattern to the value of key
+type mapEntry struct {
+	pattern interfaces.Type
+	key     interfaces.Value
+}
+
+var errNotMapPattern = fmt.Errorf("not a map pattern")
+
+// mapPattern splits a map pattern, read as (hash-map ...), into the patterns bound to each key and the name following
+// :as
+func mapPattern(exp *EXP) (entries []mapEntry, as REF, err error) {
+	if name, _ := nameOf(exp.Function); name != "hash-map" {
+		return nil, "", errNotMapPattern
+	}
+	if len(exp.Arguments)%2 > 0 {
+		return nil, "", fmt.Errorf("expected an even number of forms in %v", patternString(exp))
+	}
+	for i := 0; i < len(exp.Arguments); i += 2 {
+		key, value := exp.Arguments[i], exp.Arguments[i+1]
+		switch key {
+		case keysMarker:
+			names, ok := value.(VEC)
+			if !ok {
+				return nil, "", fmt.Errorf("expected a VEC of names after :keys in %v", patternString(exp))
+			}
+			for _, item := range names.Vector {
+				name, ok := item.(REF)
+				if !ok {
+					return nil, "", fmt.Errorf("expected a VEC of names after :keys in %v", patternString(exp))
+				}
+				entries = append(entries, mapEntry{name, SYM(":" + string(name))})
+			}
+		case asMarker:
+			name, ok := value.(REF)
+			if !ok {
+				return nil, "", fmt.Errorf("expected a name after :as in %v", patternString(exp))
+			}
+			as = name
+		default:
+			lookup, ok := value.(interfaces.Equalable)
+			if _, code := value.(interfaces.Evaluatable); !ok || code {
+				return nil, "", fmt.Errorf("expected %v to be bound to a key in %v", key, patternString(exp))
+			}
+			entries = append(entries, mapEntry{key, lookup.(interfaces.Value)})
+		}
+	}
+	return entries, as, nil
+}
+
+func itemAfter(items []interfaces.Type, i int) interfaces.Type {
+	if i+1 < len(items) {
+		return items[i+1]
+	}
+	return nil
+}
+
+// bind binds the names of pattern to the parts of value in scope. Items of VECs being bound are evaluated in from,
+// the scope in which the VEC was created.
+func bind(pattern interfaces.Type, value interfaces.Value, scope interfaces.Scope, from interfaces.Scope) error {
+	switch p := pattern.(type) {
+	case REF:
+		scope.CreateRef(p, value)
+		return nil
+	case VEC:
+		return bindSequential(p, value, scope, from)
+	case *EXP:
+		if entries, as, err := mapPattern(p); err == nil {
+			return bindMap(p, entries, as, value, scope, from)
+		} else if err != errNotMapPattern {
+			return err
+		}
+	}
+	return fmt.Errorf("unable to bind to %v, expected a name, VEC or map", patternString(pattern))
+}
+
+func bindSequential(pattern VEC, value interfaces.Value, scope interfaces.Scope, from interfaces.Scope) error {
+	items, rest, as, err := sequentialPattern(pattern)
+	if err != nil {
+		return err
+	}
+	var remaining interfaces.Iterable
+	switch v := value.(type) {
+	case VEC:
+		values, _, err := itemsOf(v, from, len(v.Vector))
+		if err != nil {
+			return err
+		}
+		remaining = NewList(values)
+	case interfaces.Iterable:
+		remaining = v
+	default:
+		return fmt.Errorf("unable to bind %v to %v, expected a list or VEC", patternString(pattern), Sprint(value, from))
+	}
+	for _, item := range items {
+		if remaining == ENDED {
+			return fmt.Errorf("unable to bind %v to %v, expected at least %d items", patternString(pattern), Sprint(value, from), len(items))
+		}
+		if err := bind(item, remaining.Head(), scope, from); err != nil {
+			return err
+		}
+		if remaining, err = remaining.Iterate(from); err != nil {
+			return err
+		}
+	}
+	if rest != nil {
+		if err := bind(rest, remaining, scope, from); err != nil {
+			return err
+		}
+	}
+	if as != "" {
+		scope.CreateRef(as, value)
+	}
+	return nil
+}
+
+func bindMap(pattern *EXP, entries []mapEntry, as REF, value interfaces.Value, scope interfaces.Scope, from interfaces.Scope) error {
+	mp, ok := value.(*MAP)
+	if !ok {
+		return fmt.Errorf("unable to bind %v to %v, expected a map", patternString(pattern), Sprint(value, from))
+	}
+	for _, entry := range entries {
+		found, _ := mp.lookup(entry.key.(interfaces.Equalable))
+		if err := bind(entry.pattern, found, scope, from); err != nil {
+			return err
+		}
+	}
+	if as != "" {
+		scope.CreateRef(as, value)
+	}
+	return nil
+}
+
+// patternString returns a pattern as it would have been written
+func patternString(pattern interfaces.Type) string {
+	if exp, ok := pattern.(*EXP); ok {
+		if name, _ := nameOf(exp.Function); name == "hash-map" {
+			items := make([]string, len(exp.Arguments))
+			for i, arg := range exp.Arguments {
+				items[i] = patternString(arg)
+			}
+			return "{" + strings.Join(items, " ") + "}"
+		}
+	}
+	if vec, ok := pattern.(VEC); ok {
+		items := make([]string, len(vec.Vector))
+		for i, item := range vec.Vector {
+			items[i] = patternString(item)
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	}
+	return fmt.Sprintf("%v", pattern)
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func vec(items ...interfaces.Type) VEC {
+	return VEC{Vector: items}
+}
+
+func mapPatternOf(items ...interfaces.Type) *EXP {
+	return EXPBuild(REF("hash-map")).withArgs(items...).build()
+}
+
+func Test_let_DestructuresSequentially(t *testing.T) {
+	//given
+	pattern := vec(REF("a"), REF("b"), REF("&"), REF("more"), SYM(":as"), REF("all"))
+	exp := EXPBuild(REF("let")).withArgs(
+		vec(pattern, vec(I(1), I(2), I(3), I(4))),
+		EXPBuild(REF("cons")).withArgs(REF("b"), REF("more")).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{I(2), P{I(3), P{I(4), ENDED}}}, result)
+}
+
+func Test_let_DestructuresMaps(t *testing.T) {
+	//given
+	pattern := mapPatternOf(SYM(":keys"), vec(REF("name"), REF("missing")), vec(REF("x"), REF("y")), SYM(":pt"))
+	value := EXPBuild(REF("hash-map")).withArgs(SYM(":name"), S("bob"), SYM(":pt"), vec(I(1), I(2))).build()
+	exp := EXPBuild(REF("let")).withArgs(
+		vec(pattern, value),
+		EXPBuild(REF("cons")).withArgs(REF("name"), EXPBuild(REF("cons")).withArgs(REF("y"), EXPBuild(REF("cons")).withArgs(REF("missing"), ENDED).build()).build()).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{S("bob"), P{I(2), P{NILL, ENDED}}}, result)
+}
+
+func Test_let_ErrorsWhenValueDoesNotMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern interfaces.Type
+		value   interfaces.Type
+		err     string
+	}{
+		{vec(REF("a"), REF("b")), vec(I(1)), "let : unable to bind [a b] to [1], expected at least 2 items"},
+		{vec(REF("a")), I(1), "let : unable to bind [a] to 1, expected a list or VEC"},
+		{mapPatternOf(SYM(":keys"), vec(REF("a"))), I(1), "let : unable to bind {:keys [a]} to 1, expected a map"},
+		{vec(REF("a"), REF("&")), vec(I(1)), "let : expected a single pattern after & in [a &]"},
+	}
+	for _, test := range tests {
+		//given
+		exp := EXPBuild(REF("let")).withArgs(vec(test.pattern, test.value), REF("a")).build()
+		//when
+		_, err := exp.Evaluate(GlobalEnvironment)
+		//then
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func Test_FN_DestructuresArguments(t *testing.T) {
+	//given
+	fn := FN{
+		Arguments:  vec(vec(REF("a"), REF("b")), mapPatternOf(REF("c"), SYM(":c"))),
+		Expression: EXPBuild(REF("+")).withArgs(REF("a"), REF("b"), REF("c")).build(),
+	}
+	arg := EXPBuild(REF("hash-map")).withArgs(SYM(":c"), I(3)).build()
+	//when
+	result, err := fn.Apply([]interfaces.Type{vec(I(1), I(2)), arg}, GlobalEnvironment.NewChildScope())
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(6), result)
+}
+
+func Test_loop_DestructuresRecurValues(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(
+		vec(vec(REF("x"), REF("&"), REF("xs")), EXPBuild(REF("range")).withArgs(I(1), I(4)).build(), REF("acc"), I(0)),
+		EXPBuild(REF("if")).withArgs(
+			EXPBuild(REF("empty")).withArgs(REF("xs")).build(),
+			EXPBuild(REF("+")).withArgs(REF("acc"), REF("x")).build(),
+			EXPBuild(REF("recur")).withArgs(REF("xs"), EXPBuild(REF("+")).withArgs(REF("acc"), REF("x")).build()).build(),
+		).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(10), result)
+}
+
+func Test_BoundNames_ReturnsNamesInOrderBound(t *testing.T) {
+	//given
+	pattern := vec(REF("a"), mapPatternOf(SYM(":keys"), vec(REF("b")), SYM(":as"), REF("c")), REF("&"), vec(REF("d")))
+	//when
+	names, err := BoundNames(pattern)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, []REF{"a", "b", "c", "d"}, names)
+}
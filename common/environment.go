@@ -3,101 +3,312 @@ package common
 import (
 	"fmt"
 	"github.com/mikeyhu/glipso/interfaces"
+	"sync"
+	"sync/atomic"
 )
 
 // Environment Provides a mechanism for creating and resolving variables
+// evaluation is the Evaluation that code evaluated within the Environment runs under, see WithContext
 type Environment struct {
-	id        int
-	variables map[REF]interfaces.Value
-	parent    *Environment
+	id         int
+	variables  *variables
+	parent     *Environment
+	globals    *globals
+	evaluation *Evaluation
+}
+
+// variables holds the variables created in an Environment, guarded by mutex so that they can be looked up while
+// another goroutine evaluates a def. version changes whenever a variable is created, so that GREFs know when the value
+// they cached may be stale.
+type variables struct {
+	mutex   sync.RWMutex
+	values  map[REF]interfaces.Value
+	version atomic.Int64
+}
+
+func newVariables() *variables {
+	return &variables{values: map[REF]interfaces.Value{}}
+}
+
+func (vars *variables) lookup(ref REF) (interfaces.Value, bool) {
+	vars.mutex.RLock()
+	defer vars.mutex.RUnlock()
+	value, ok := vars.values[ref]
+	return value, ok
+}
+
+// create sets ref to value, unless absent is true and ref already exists, returning true if it was set
+func (vars *variables) create(ref REF, value interfaces.Value, absent bool) bool {
+	vars.mutex.Lock()
+	defer vars.mutex.Unlock()
+	if _, exists := vars.values[ref]; exists && absent {
+		return false
+	}
+	vars.values[ref] = value
+	vars.version.Add(1)
+	return true
+}
+
+// copy returns a copy of the values, for display or to hand out
+func (vars *variables) copy() map[REF]interfaces.Value {
+	vars.mutex.RLock()
+	defer vars.mutex.RUnlock()
+	values := make(map[REF]interfaces.Value, len(vars.values))
+	for k, v := range vars.values {
+		values[k] = v
+	}
+	return values
+}
+
+// globals holds what is shared by a global Environment and every scope created from it: inbuilt functions added to
+// it alone, the debug setting, a count of the scopes created and the Limits of each evaluation
+type globals struct {
+	env     *Environment
+	inbuilt *variables
+	debug   bool
+	scopes  atomic.Int64
+	limits  Limits
+}
+
+// nextScopeID counts a scope created under evaluation, returning its id
+func (g *globals) nextScopeID(evaluation *Evaluation) int {
+	evaluation.AllocateScope()
+	return int(g.scopes.Add(1))
+}
+
+// NewEnvironment creates a global Environment, independent of any other
+func NewEnvironment() *Environment {
+	g := &globals{inbuilt: newVariables()}
+	g.env = &Environment{
+		id:        g.nextScopeID(nil),
+		variables: newVariables(),
+		globals:   g,
+	}
+	return g.env
+}
+
+// GlobalOf returns the global Environment that sco was created from, or GlobalEnvironment for any other Scope
+func GlobalOf(sco interfaces.Scope) *Environment {
+	return globalsOf(sco).env
+}
+
+func globalsOf(sco interfaces.Scope) *globals {
+	switch s := sco.(type) {
+	case *Environment:
+		return s.globals
+	case *lexicalScope:
+		return s.globals
+	}
+	return GlobalEnvironment.globals
+}
+
+// AddInbuilt adds fi to the inbuilt functions of the global Environment of env, hiding any inbuilt function of the
+// same name
+func (env *Environment) AddInbuilt(fi FI) {
+	g := env.globals
+	g.inbuilt.create(REF(fi.name), fi, false)
+	// GREFs cache inbuilt functions as well as variables, so they must look them up again too
+	g.env.variables.version.Add(1)
+}
+
+// SetDebug enables the display of debug information when evaluating within this Environment or any scope created
+// from it. It should be set before evaluating, as should the Limits.
+func (env *Environment) SetDebug(debug bool) {
+	env.globals.debug = debug
+}
+
+// SetLimits sets the Limits of each evaluation started by WithContext within the global Environment of env
+func (env *Environment) SetLimits(limits Limits) {
+	env.globals.limits = limits
 }
 
 // ResolveRef will try to resolve a provided reference to a value in this or parent scope
-func (env Environment) ResolveRef(ref interfaces.Type) (interfaces.Value, bool) {
-	if env.variables != nil {
-		if result, ok := env.variables[ref.(REF)]; ok {
-			if DEBUG {
-				fmt.Printf("found %v in scope %v\n", ref, env.id)
-			}
-			return result, true
+func (env *Environment) ResolveRef(ref interfaces.Type) (interfaces.Value, bool) {
+	if global, ok := ref.(*GREF); ok {
+		if global.env.variables == env.variables {
+			return global.env.resolveGlobal(global)
 		}
 	}
+	name, _ := nameOf(ref)
+	return env.lookup(name)
+}
+
+func (env *Environment) lookup(ref REF) (interfaces.Value, bool) {
+	if result, ok := env.variables.lookup(ref); ok {
+		if env.globals.debug {
+			fmt.Printf("found %v in scope %v\n", ref, env.id)
+		}
+		return result, true
+	}
 	if env.parent != nil {
-		return env.parent.ResolveRef(ref)
+		return env.parent.lookup(ref)
 	}
-	if fi, ok := inbuilt[ref.(REF)]; ok {
-		if DEBUG {
-			fmt.Printf("found %v in inbuilt\n", ref)
-		}
-		return fi, true
+	fi, ok := env.globals.inbuilt.lookup(ref)
+	if !ok {
+		fi, ok = inbuilt[ref]
 	}
-	return nil, false
+	if ok && env.globals.debug {
+		fmt.Printf("found %v in inbuilt\n", ref)
+	}
+	return fi, ok
+}
+
+// resolveGlobal returns the value cached by a GREF, looking it up again if a variable has been created since. The
+// version is read before the value is looked up, so that a value cached while another goroutine creates a variable is
+// looked up again next time.
+func (env *Environment) resolveGlobal(global *GREF) (interfaces.Value, bool) {
+	version := env.variables.version.Load()
+	if cached := global.cached.Load(); cached != nil && cached.version == version {
+		return cached.value, true
+	}
+	value, ok := env.lookup(global.Name)
+	if ok {
+		global.cached.Store(&cachedValue{value, version})
+	}
+	return value, ok
 }
 
 // CreateRef will create a variable in this scope
 func (env *Environment) CreateRef(name interfaces.Type, arg interfaces.Value) interfaces.Type {
-	if DEBUG {
+	if env.globals.debug {
 		fmt.Printf("Adding %v %v to %v\n", name, arg, env)
 	}
-	if env.variables == nil {
-		env.variables = map[REF]interfaces.Value{}
-	}
-	env.variables[name.(REF)] = arg
+	ref, _ := nameOf(name)
+	env.variables.create(ref, arg, false)
 	return name
 }
 
+// declare creates a variable holding NIL in this scope, unless one of that name already exists
+func (env *Environment) declare(ref REF) {
+	if env.variables.create(ref, NILL, true) && env.globals.debug {
+		fmt.Printf("Adding %v %v to %v\n", ref, NILL, env)
+	}
+}
+
 // NewChildScope creates new scope that inherits from this one
-func (env Environment) NewChildScope() interfaces.Scope {
-	id := nextScopeID()
-	if DEBUG {
+func (env *Environment) NewChildScope() interfaces.Scope {
+	id := env.globals.nextScopeID(env.evaluation)
+	if env.globals.debug {
 		fmt.Printf("New scope %d from %d\n", id, env.id)
 	}
 	return &Environment{
-		id:     id,
-		parent: &env,
+		id:         id,
+		variables:  newVariables(),
+		parent:     env,
+		globals:    env.globals,
+		evaluation: env.evaluation,
 	}
 }
 
+// Variables returns a copy of the variables created in env
+func (env *Environment) Variables() map[REF]interfaces.Value {
+	return env.variables.copy()
+}
+
 // DisplayEnvironment is used to display environment information for internal debugging
-func (env Environment) DisplayEnvironment() {
-	if DEBUG {
+func (env *Environment) DisplayEnvironment() {
+	if env.globals.debug {
 		env.displayEnvironment(0)
 	}
 }
 
-func (env Environment) displayEnvironment(i int) {
-	for k := range env.variables {
-		fmt.Printf("Scope[%d %d] %v := %v\n", env.id, i, k, env.variables[k])
+func (env *Environment) displayEnvironment(i int) {
+	for k, v := range env.variables.copy() {
+		fmt.Printf("Scope[%d %d] %v := %v\n", env.id, i, k, v)
 	}
 	if env.parent != nil {
 		env.parent.displayEnvironment(i + 1)
 	}
 }
 
-func (env Environment) String() string {
+func (env *Environment) String() string {
 	return fmt.Sprintf("ENV{%d}", env.id)
 }
 
-// GlobalEnvironment acts as the global scope for variables
-var GlobalEnvironment *Environment
+// lexicalScope holds the variables bound by a fn, let or loop in slices, in the order they were bound, so that an
+// LREF can find its value by Index rather than by name
+type lexicalScope struct {
+	id         int
+	names      []REF
+	values     []interfaces.Value
+	parent     interfaces.Scope
+	globals    *globals
+	evaluation *Evaluation
+}
 
-func init() {
-	GlobalEnvironment = &Environment{
-		id:        nextScopeID(),
-		variables: map[REF]interfaces.Value{},
+// newLexicalScope creates a lexicalScope within parent, evaluated under the same Evaluation as parent
+func newLexicalScope(parent interfaces.Scope, size int) *lexicalScope {
+	return newLexicalScopeUnder(parent, EvaluationOf(parent), size)
+}
+
+// newLexicalScopeUnder creates a lexicalScope within parent evaluated under evaluation, which is that of the caller
+// when parent is the scope that a function or macro was created in
+func newLexicalScopeUnder(parent interfaces.Scope, evaluation *Evaluation, size int) *lexicalScope {
+	g := globalsOf(parent)
+	id := g.nextScopeID(evaluation)
+	if g.debug {
+		fmt.Printf("New scope %d from %v\n", id, parent)
+	}
+	return &lexicalScope{
+		id:         id,
+		names:      make([]REF, 0, size),
+		values:     make([]interfaces.Value, 0, size),
+		parent:     parent,
+		globals:    g,
+		evaluation: evaluation,
+	}
+}
+
+// ResolveRef will try to resolve a provided reference to a value in this or parent scope, later bindings of a name
+// hiding earlier ones
+func (sco *lexicalScope) ResolveRef(ref interfaces.Type) (interfaces.Value, bool) {
+	name, _ := nameOf(ref)
+	for i := len(sco.names) - 1; i >= 0; i-- {
+		if sco.names[i] == name {
+			if sco.globals.debug {
+				fmt.Printf("found %v in scope %v\n", ref, sco.id)
+			}
+			return sco.values[i], true
+		}
 	}
+	return sco.parent.ResolveRef(ref)
+}
+
+// CreateRef will create a variable in this scope
+func (sco *lexicalScope) CreateRef(name interfaces.Type, arg interfaces.Value) interfaces.Type {
+	if sco.globals.debug {
+		fmt.Printf("Adding %v %v to %v\n", name, arg, sco)
+	}
+	ref, _ := nameOf(name)
+	sco.names = append(sco.names, ref)
+	sco.values = append(sco.values, arg)
+	return name
 }
 
-var scopeID = 0
+// NewChildScope creates new scope that inherits from this one
+func (sco *lexicalScope) NewChildScope() interfaces.Scope {
+	return newLexicalScope(sco, 0)
+}
+
+func (sco *lexicalScope) String() string {
+	return fmt.Sprintf("ENV{%d}", sco.id)
+}
+
+// GlobalEnvironment is a default global Environment, for when only one is needed
+var GlobalEnvironment *Environment
+
+func init() {
+	GlobalEnvironment = NewEnvironment()
+}
 
-func nextScopeID() int {
-	scopeID = scopeID + 1
-	return scopeID
+// ScopesCreated returns the number of scopes created from the global Environment of env, including itself
+func (env *Environment) ScopesCreated() int {
+	return int(env.globals.scopes.Load())
 }
 
 // DisplayDiagnostics outputs Information about the Scopes
-func (env Environment) DisplayDiagnostics() {
-	if DEBUG {
-		fmt.Printf("Total number of scopes created: %v", scopeID)
+func (env *Environment) DisplayDiagnostics() {
+	if env.globals.debug {
+		fmt.Printf("Total number of scopes created: %v", env.ScopesCreated())
 	}
 }
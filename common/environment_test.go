@@ -15,3 +15,38 @@ func Test_Environment_ReturnsReferencesIfFound(t *testing.T) {
 	result, _ := GlobalEnvironment.ResolveRef(REF("one"))
 	assert.Equal(t, I(1), result)
 }
+
+func Test_Environment_DefWritesToGlobalEnvironmentOfScope(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	exp := EXPBuild(REF("def")).withArgs(REF("definedinenv"), I(1)).build()
+
+	//when
+	_, err := exp.Evaluate(env.NewChildScope())
+
+	//then
+	assert.NoError(t, err)
+	result, ok := env.ResolveRef(REF("definedinenv"))
+	assert.True(t, ok)
+	assert.Equal(t, I(1), result)
+	_, ok = GlobalEnvironment.ResolveRef(REF("definedinenv"))
+	assert.False(t, ok)
+}
+
+func Test_Environment_DefWithinLexicalScopeIsLocal(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	scope := newLexicalScope(env, 0)
+	exp := EXPBuild(REF("def")).withArgs(REF("definedinscope"), I(1)).build()
+
+	//when
+	_, err := exp.Evaluate(scope)
+
+	//then
+	assert.NoError(t, err)
+	result, ok := scope.ResolveRef(REF("definedinscope"))
+	assert.True(t, ok)
+	assert.Equal(t, I(1), result)
+	_, ok = env.ResolveRef(REF("definedinscope"))
+	assert.False(t, ok)
+}
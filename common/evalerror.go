@@ -0,0 +1,80 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"strings"
+)
+
+// Frame records a function application that an EvalError passed through while unwinding
+type Frame struct {
+	Name      string
+	Arguments string
+	Pos       Pos
+	Function  interfaces.Appliable
+}
+
+// String representation of a Frame, showing the call and where it was made
+func (f Frame) String() string {
+	call := fmt.Sprintf("(%s %s)", f.Name, f.Arguments)
+	if f.Arguments == "" {
+		call = fmt.Sprintf("(%s)", f.Name)
+	}
+	if f.Pos.IsValid() {
+		return fmt.Sprintf("%s at %v", call, f.Pos)
+	}
+	return call
+}
+
+// EvalError is returned when evaluation fails, it collects a stack of Frames as it propagates back up through the
+// Expressions that were being evaluated. The first Frame is the innermost function application.
+type EvalError struct {
+	Err    error
+	Pos    Pos
+	Frames []Frame
+}
+
+// Error returns the position, if known, and message of the EvalError
+func (e *EvalError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%v: %v", e.Pos, e.Err)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the error that caused evaluation to fail
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// StackTrace returns the Frames of the EvalError, one per line, innermost first
+func (e *EvalError) StackTrace() string {
+	lines := make([]string, len(e.Frames))
+	for i, frame := range e.Frames {
+		lines[i] = "\tin " + frame.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// asEvalError returns the EvalError within err, so that one wrapped by another error keeps collecting Frames rather
+// than being wrapped again, or a new EvalError if there is none
+func asEvalError(err error) *EvalError {
+	var evalErr *EvalError
+	if errors.As(err, &evalErr) {
+		return evalErr
+	}
+	return &EvalError{Err: err}
+}
+
+const maxArgumentSummary = 60
+
+// SummariseArguments returns the arguments of a function application for a Frame, truncated if they are long
+func SummariseArguments(arguments []interfaces.Type) string {
+	argAsS := fmt.Sprintf("%v", arguments)
+	summary := argAsS[1 : len(argAsS)-1]
+	if len(summary) > maxArgumentSummary {
+		return summary[:maxArgumentSummary-3] + "..."
+	}
+	return summary
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_EvalError_ReturnedFromEvaluate(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("first")).withArgs(B(true)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	var evalErr *EvalError
+	assert.True(t, errors.As(err, &evalErr))
+	assert.EqualError(t, evalErr.Err, "first : true is not of type Iterable")
+}
+
+func Test_EvalError_CollectsFramesInnermostFirst(t *testing.T) {
+	//given
+	source := NewSource("file.glipso", "(do (add1 true) 1)")
+	add1 := FNBuild().withArgs(REF("a")).withEXPBuilder(EXPBuild(REF("+")).withArgs(REF("a"), I(1))).build()
+	GlobalEnvironment.CreateRef(REF("add1"), add1)
+	exp := &EXP{
+		Function: REF("do"),
+		Arguments: []interfaces.Type{&EXP{
+			Function:  REF("add1"),
+			Arguments: []interfaces.Type{B(true)},
+			Pos:       Pos{source, 1, 5},
+		}, I(1)},
+		Pos: Pos{source, 1, 1},
+	}
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	evalErr := err.(*EvalError)
+	assert.Equal(t, 3, len(evalErr.Frames))
+	assert.Equal(t, "(+ a 1)", evalErr.Frames[0].String())
+	assert.Equal(t, "(add1 true) at file.glipso:1:5", evalErr.Frames[1].String())
+	assert.Equal(t, add1, evalErr.Frames[1].Function)
+	assert.Equal(t, "(do (add1 true) 1) at file.glipso:1:1", evalErr.Frames[2].String())
+}
+
+func Test_EvalError_TailCallsDoNotAddFrames(t *testing.T) {
+	//given
+	add1 := FNBuild().withArgs(REF("a")).withEXPBuilder(EXPBuild(REF("+")).withArgs(REF("a"), I(1))).build()
+	GlobalEnvironment.CreateRef(REF("add1"), add1)
+	exp := EXPBuild(REF("do")).withArgs(EXPBuild(REF("add1")).withArgs(B(true)).build()).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	evalErr := err.(*EvalError)
+	assert.Equal(t, 2, len(evalErr.Frames))
+	assert.Equal(t, "(+ a 1)", evalErr.Frames[0].String())
+	assert.Equal(t, "(do (add1 true))", evalErr.Frames[1].String())
+}
+
+func Test_EvalError_NoFrameWhenFunctionNotFound(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("not-a-function")).withArgs(I(1)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Empty(t, err.(*EvalError).Frames)
+}
+
+func Test_ErrorDetail_IncludesStackTrace(t *testing.T) {
+	//given
+	source := NewSource("file.glipso", "(first true)")
+	exp := &EXP{
+		Function:  REF("first"),
+		Arguments: []interfaces.Type{B(true)},
+		Pos:       Pos{source, 1, 1},
+	}
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, "file.glipso:1:1: first : true is not of type Iterable\n(first true)\n^\n\tin (first true) at file.glipso:1:1", ErrorDetail(err))
+}
+
+func Test_EvalError_WrappedEvalErrorKeepsCollectingFrames(t *testing.T) {
+	//given
+	wraps := NewFI("wraps", func(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+		_, err := EXPBuild(REF("first")).withArgs(B(true)).build().Evaluate(sco)
+		return NILL, fmt.Errorf("wraps : %w", err)
+	})
+	exp := EXPBuild(wraps).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	evalErr := err.(*EvalError)
+	assert.EqualError(t, evalErr, "first : true is not of type Iterable")
+	assert.Equal(t, []string{"(first true)", "(FI(wraps))"}, []string{evalErr.Frames[0].String(), evalErr.Frames[1].String()})
+}
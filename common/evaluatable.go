@@ -1,13 +1,11 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mikeyhu/glipso/interfaces"
 )
 
-// DEBUG enable to display debug information for function evaluation
-var DEBUG = false
-
 func evaluateToValue(value interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
 	switch v := value.(type) {
 	case interfaces.Evaluatable:
@@ -19,10 +17,20 @@ func evaluateToValue(value interfaces.Type, sco interfaces.Scope) (interfaces.Va
 	}
 }
 
+// Evaluate evaluates code in sco with the tree walking evaluator, having first resolved its REFs with Resolve
+func Evaluate(code interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	sco = Evaluating(sco)
+	return evaluateToValue(Resolve(code, sco), sco)
+}
+
 // EXP is a an Expression that has a Appliable and Arguments and can be evaluated against a scope
+// Pos and Positions are set when the EXP is parsed, Positions holding the location of the Function followed by each of the Arguments
+// REFs and literals have no position of their own, so one made by a macro or by Code is reported at the enclosing EXP
 type EXP struct {
 	Function  interfaces.Type
 	Arguments []interfaces.Type
+	Pos       Pos
+	Positions []Pos
 }
 
 // IsType for EXP
@@ -36,55 +44,153 @@ func (exp *EXP) String() string {
 
 // Evaluate evaluates the Appliable provided with the Arguments and Scope
 func (exp *EXP) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
-	exp.printStartExpression()
+	return exp.evaluate(sco, false)
+}
+
+// evaluate applies the Appliable to the Arguments, when in tail position it may return a tailCall for the caller's
+// trampoline to evaluate, otherwise tailCalls are evaluated before returning
+func (exp *EXP) evaluate(sco interfaces.Scope, tail bool) (interfaces.Value, error) {
+	exp.printStartExpression(sco)
+	evaluation := EvaluationOf(sco)
+	if err := evaluation.Step(); err != nil {
+		return exp.returnAndPrint(sco, NILL, exp.fail(err, nil))
+	}
 	var result interfaces.Value
 	var err error
 	function := exp.Function
 
-	if toREF, ok := function.(REF); ok {
-		if fn, ok := sco.ResolveRef(toREF); ok {
+	if toREF, ok := function.(reference); ok {
+		if fn, ok := toREF.resolve(sco); ok {
 			function = fn
 		} else {
-			return exp.returnAndPrint(NILL, fmt.Errorf("evaluate : function '%v' not found", toREF))
+			name, _ := nameOf(toREF)
+			return exp.returnAndPrint(sco, NILL, exp.fail(&unresolvedError{name, fmt.Sprintf("evaluate : function '%v' not found", name)}, nil))
 		}
 	}
 
 	if toMacro, ok := function.(interfaces.Expandable); ok {
-		result, err = toMacro.Expand(exp.Arguments).Evaluate(sco)
+		debug := globalsOf(sco).debug
+		if debug {
+			fmt.Printf("Expanding %v\n", toMacro)
+		}
+		// the expansion is evaluated within the same Depth, so that macros expanding to calls of themselves are bounded
+		if err = evaluation.Enter(); err != nil {
+			return exp.returnAndPrint(sco, NILL, exp.fail(err, nil))
+		}
+		var expanded interfaces.Type
+		expanded, err = toMacro.Expand(exp.Arguments, sco)
 		if err != nil {
-			return exp.returnAndPrint(NILL, err)
+			evaluation.Leave(1)
+			return exp.returnAndPrint(sco, NILL, exp.fail(err, nil))
+		}
+		if debug {
+			fmt.Printf("Expanded to %v\n", expanded)
+		}
+		expanded = Resolve(expanded, sco)
+		if toEvaluatable, ok := expanded.(interfaces.Evaluatable); ok && tail {
+			evaluation.Leave(1)
+			return exp.returnAndPrint(sco, &tailCall{toEvaluatable, sco}, nil)
+		}
+		result, err = evaluateToValue(expanded, sco)
+		evaluation.Leave(1)
+		if err != nil {
+			return exp.returnAndPrint(sco, NILL, exp.fail(err, nil))
 		}
 	} else {
 		function, err := evaluateToValue(function, sco)
 		if err != nil {
-			return exp.returnAndPrint(NILL, err)
+			return exp.returnAndPrint(sco, NILL, exp.fail(err, nil))
 		}
 		if toFN, ok := function.(interfaces.Appliable); ok {
-			result, err = toFN.Apply(exp.Arguments, sco)
+			if toTail, ok := toFN.(tailAppliable); ok {
+				result, err = toTail.applyTail(exp.Arguments, sco)
+				if err == nil && !tail {
+					if err = evaluation.Enter(); err == nil {
+						result, err = trampoline(result, nil)
+						evaluation.Leave(1)
+					}
+				}
+			} else {
+				result, err = toFN.Apply(exp.Arguments, sco)
+			}
 			if err != nil {
-				return exp.returnAndPrint(NILL, err)
+				return exp.returnAndPrint(sco, NILL, exp.fail(err, toFN))
 			}
+		} else {
+			return exp.returnAndPrint(sco, NILL, exp.fail(fmt.Errorf("evaluate : '%v' is not a function", function), nil))
 		}
 	}
-	return exp.returnAndPrint(result, nil)
+	return exp.returnAndPrint(sco, result, nil)
 }
 
-func (exp *EXP) printStartExpression() {
-	if DEBUG {
+func (exp *EXP) printStartExpression(sco interfaces.Scope) {
+	if globalsOf(sco).debug {
 		fmt.Printf("%v = ?\n", exp)
 	}
 }
 
-func (exp *EXP) returnAndPrint(result interfaces.Value, err error) (interfaces.Value, error) {
-	if err != nil {
-		fmt.Printf("%v\n\tcaused ERROR : %v\n", exp, err)
-	}
-	if DEBUG {
+func (exp *EXP) returnAndPrint(sco interfaces.Scope, result interfaces.Value, err error) (interfaces.Value, error) {
+	if globalsOf(sco).debug {
 		fmt.Printf("%v = %v\n", exp, result)
 	}
 	return result, err
 }
 
+// fail converts an error into an EvalError, recording where it happened if not already known and adding a Frame
+// for the function being applied
+func (exp *EXP) fail(err error, function interfaces.Appliable) error {
+	evalErr := asEvalError(err)
+	if !evalErr.Pos.IsValid() {
+		evalErr.Pos = exp.locate(err)
+	}
+	if function != nil {
+		evalErr.Frames = append(evalErr.Frames, Frame{
+			Name:      fmt.Sprintf("%v", exp.Function),
+			Arguments: SummariseArguments(exp.Arguments),
+			Pos:       exp.Pos,
+			Function:  function,
+		})
+	}
+	return evalErr
+}
+
+// locate finds the position of the EXP, or of the unresolved REF within it, that caused an error
+func (exp *EXP) locate(err error) Pos {
+	var refErr *unresolvedError
+	if errors.As(err, &refErr) {
+		if pos := exp.positionOfREF(refErr.ref); pos.IsValid() {
+			return pos
+		}
+	}
+	return exp.Pos
+}
+
+func (exp *EXP) positionOfREF(ref REF) Pos {
+	for p, item := range append([]interfaces.Type{exp.Function}, exp.Arguments...) {
+		if name, ok := nameOf(item); ok && name == ref && p < len(exp.Positions) {
+			return exp.Positions[p]
+		}
+		if vec, ok := item.(VEC); ok {
+			for v, vecItem := range vec.Vector {
+				if name, ok := nameOf(vecItem); ok && name == ref && v < len(vec.Positions) {
+					return vec.Positions[v]
+				}
+			}
+		}
+	}
+	return Pos{}
+}
+
+// unresolvedError is returned when a REF cannot be found in scope
+type unresolvedError struct {
+	ref     REF
+	message string
+}
+
+func (e *unresolvedError) Error() string {
+	return e.message
+}
+
 // REF (Reference)
 // symbol for something in scope, variable or function
 type REF string
@@ -99,15 +205,17 @@ func (r REF) String() string {
 
 // Evaluate resolves a REF to something in scope
 func (r REF) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
-	if DEBUG {
-		env := sco.(*Environment)
-		fmt.Printf("%v being looked up in scope %v:\n", r, env.id)
-		env.DisplayEnvironment()
-	}
-	if resolved, ok := sco.ResolveRef(r); ok {
-		return resolved, nil
+	if globalsOf(sco).debug {
+		fmt.Printf("%v being looked up in scope %v:\n", r, sco)
+		if env, ok := sco.(*Environment); ok {
+			env.DisplayEnvironment()
+		}
 	}
-	return NILL, fmt.Errorf("unable to resolve REF('%v')", r)
+	return evaluateReference(r, sco)
+}
+
+func (r REF) resolve(sco interfaces.Scope) (interfaces.Value, bool) {
+	return sco.ResolveRef(r)
 }
 
 // BOUNDEXP provides a way for a Expression to be bound to a particular scope for later evaluation
@@ -116,9 +224,10 @@ type BOUNDEXP struct {
 	Scope       interfaces.Scope
 }
 
-// Evaluate on a Bound Expression replaces the provided scope with the bound scope
+// Evaluate on a Bound Expression replaces the provided scope with the bound scope, evaluated under the Evaluation of
+// the provided scope
 func (bexp *BOUNDEXP) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
-	return bexp.Evaluatable.Evaluate(bexp.Scope)
+	return bexp.Evaluatable.Evaluate(Within(bexp.Scope, EvaluationOf(sco)))
 }
 
 // String representation of a BEXP
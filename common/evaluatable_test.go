@@ -38,8 +38,8 @@ func Test_Evaluate_NestedExpression(t *testing.T) {
 
 func Test_Evaluate_FN(t *testing.T) {
 	exp := EXP{Function: FN{
-		VEC{[]interfaces.Type{REF("a")}},
-		&EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("a"), I(1)}}},
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: &EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("a"), I(1)}}},
 		Arguments: []interfaces.Type{I(2)}}
 	result, err := exp.Evaluate(GlobalEnvironment)
 	assert.NoError(t, err)
@@ -48,8 +48,8 @@ func Test_Evaluate_FN(t *testing.T) {
 
 func Test_Evaluate_FNHasMoreArgumentsThanProvided(t *testing.T) {
 	exp := EXP{Function: FN{
-		VEC{[]interfaces.Type{REF("a"), REF("b")}},
-		&EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("a"), I(1)}}},
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a"), REF("b")}},
+		Expression: &EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("a"), I(1)}}},
 		Arguments: []interfaces.Type{I(2)}}
 
 	result, err := exp.Evaluate(GlobalEnvironment)
@@ -59,8 +59,8 @@ func Test_Evaluate_FNHasMoreArgumentsThanProvided(t *testing.T) {
 
 func Test_Evaluate_FNHasLessArgumentsThanProvided(t *testing.T) {
 	exp := EXP{Function: FN{
-		VEC{[]interfaces.Type{REF("a")}},
-		&EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("a"), I(1)}}},
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: &EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("a"), I(1)}}},
 		Arguments: []interfaces.Type{I(2), I(3)}}
 
 	result, err := exp.Evaluate(GlobalEnvironment)
@@ -83,6 +83,14 @@ func Test_Evaluate_FunctionNotFound(t *testing.T) {
 	assert.EqualError(t, err, "evaluate : function 'not-a-function' not found")
 }
 
+func Test_Evaluate_ValueIsNotAFunction(t *testing.T) {
+	exp := EXP{Function: I(1), Arguments: []interfaces.Type{I(2)}}
+
+	result, err := exp.Evaluate(GlobalEnvironment)
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "evaluate : '1' is not a function")
+}
+
 func Test_Evaluate_ValueIsNeitherEvaluatableOrResult(t *testing.T) {
 	exp := EXP{}
 	result, err := exp.Evaluate(GlobalEnvironment)
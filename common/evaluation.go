@@ -0,0 +1,198 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"sync/atomic"
+)
+
+// Limits are quotas on a single evaluation within a global Environment, a limit of 0 is unlimited other than Depth,
+// which is then DefaultDepth. Steps limits the expressions evaluated, functions applied and elements of lazy lists
+// produced, Depth the functions being applied at once, not counting calls in tail position, Cells the list cells
+// created and Scopes the scopes created. Cells and Scopes are approximate, they are checked at the next step after
+// being exceeded.
+type Limits struct {
+	Steps  int
+	Depth  int
+	Cells  int
+	Scopes int
+}
+
+// DefaultDepth is the Depth of an evaluation whose Limits have none, so that code recursing without end returns a
+// QuotaError before it exhausts the stack of the goroutine evaluating it
+const DefaultDepth = 20000
+
+// Quota names one of the Limits
+type Quota string
+
+// The Quotas that a QuotaError may report as exceeded
+const (
+	StepsQuota  Quota = "steps"
+	DepthQuota  Quota = "depth"
+	CellsQuota  Quota = "cells"
+	ScopesQuota Quota = "scopes"
+)
+
+// QuotaError is returned when an evaluation exceeds one of its Limits, so that it can be told apart from code that
+// failed by itself
+type QuotaError struct {
+	Quota Quota
+	Limit int
+}
+
+// Error for QuotaError
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota : %v limit of %d exceeded", e.Quota, e.Limit)
+}
+
+// Evaluation holds the context that a single evaluation is running under, and counts what it uses against its Limits.
+// done is set once the context is done or a limit is exceeded, so that checking it is cheap. The methods of a nil
+// Evaluation do nothing.
+type Evaluation struct {
+	ctx      context.Context
+	limits   Limits
+	done     atomic.Bool
+	exceeded atomic.Pointer[QuotaError]
+	steps    atomic.Int64
+	depth    atomic.Int64
+	cells    atomic.Int64
+	scopes   atomic.Int64
+}
+
+// EvaluateContext is Evaluate, stopping once ctx is cancelled or its deadline passes. The error returned is then an
+// EvalError wrapping context.Canceled or context.DeadlineExceeded.
+func EvaluateContext(ctx context.Context, code interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	scope, release := WithContext(ctx, sco)
+	defer release()
+	return Evaluate(code, scope)
+}
+
+// WithContext starts an Evaluation, limited by the Limits of the global Environment of sco, that stops once ctx is
+// done. It returns a copy of sco to evaluate code in under the Evaluation, and every scope created from that, and every
+// function applied from it, uses the Evaluation too. Evaluations running at the same time therefore have their own
+// deadlines and quotas. release should be called once evaluation has finished.
+func WithContext(ctx context.Context, sco interfaces.Scope) (scope interfaces.Scope, release func()) {
+	e := &Evaluation{ctx: ctx, limits: globalsOf(sco).limits}
+	e.done.Store(ctx.Err() != nil)
+	stop := context.AfterFunc(ctx, func() {
+		e.done.Store(true)
+	})
+	return Within(sco, e), func() { stop() }
+}
+
+// EvaluationOf returns the Evaluation that code evaluated in sco runs under, or nil if there is none
+func EvaluationOf(sco interfaces.Scope) *Evaluation {
+	switch s := sco.(type) {
+	case *Environment:
+		return s.evaluation
+	case *lexicalScope:
+		return s.evaluation
+	}
+	return nil
+}
+
+// Evaluating returns sco, or should code evaluated in it not run under an Evaluation, a copy of it under a new one
+// limited only by DefaultDepth
+func Evaluating(sco interfaces.Scope) interfaces.Scope {
+	if EvaluationOf(sco) != nil {
+		return sco
+	}
+	return Within(sco, &Evaluation{ctx: context.Background()})
+}
+
+// Within returns sco, or a copy of it, in which code is evaluated under e. It is used where code runs in a scope
+// captured earlier, such as the tail of a lazy list, so that it counts against the evaluation that needs its result
+// rather than the one it was created by. A copy of a lexicalScope holds the variables bound so far, any it binds
+// itself are not seen by sco.
+func Within(sco interfaces.Scope, e *Evaluation) interfaces.Scope {
+	switch s := sco.(type) {
+	case *Environment:
+		if s.evaluation == e {
+			return s
+		}
+		within := *s
+		within.evaluation = e
+		return &within
+	case *lexicalScope:
+		if s.evaluation == e {
+			return s
+		}
+		within := *s
+		within.names = s.names[:len(s.names):len(s.names)]
+		within.values = s.values[:len(s.values):len(s.values)]
+		within.evaluation = e
+		return &within
+	}
+	return sco
+}
+
+// Err returns the QuotaError for the first limit exceeded, or the error of the context once it is done
+func (e *Evaluation) Err() error {
+	if e == nil || !e.done.Load() {
+		return nil
+	}
+	if exceeded := e.exceeded.Load(); exceeded != nil {
+		return exceeded
+	}
+	return e.ctx.Err()
+}
+
+// Step counts an expression evaluated or function applied, returning an error if the Evaluation should stop
+func (e *Evaluation) Step() error {
+	if e == nil {
+		return nil
+	}
+	if err := e.Err(); err != nil {
+		return err
+	}
+	return e.count(&e.steps, 1, StepsQuota, e.limits.Steps)
+}
+
+// Enter counts a function being applied, which Leave must be called for once it has returned unless Enter fails
+func (e *Evaluation) Enter() error {
+	if e == nil {
+		return nil
+	}
+	depth := e.limits.Depth
+	if depth == 0 {
+		depth = DefaultDepth
+	}
+	if err := e.count(&e.depth, 1, DepthQuota, depth); err != nil {
+		e.depth.Add(-1)
+		return err
+	}
+	return nil
+}
+
+// Leave counts n functions that have returned
+func (e *Evaluation) Leave(n int) {
+	if e != nil {
+		e.depth.Add(int64(-n))
+	}
+}
+
+// Allocate counts n list cells created, the Evaluation stopping at its next Step should there be too many
+func (e *Evaluation) Allocate(n int) {
+	if e != nil {
+		e.count(&e.cells, n, CellsQuota, e.limits.Cells)
+	}
+}
+
+// AllocateScope counts a scope created, or a compiled function entered, the Evaluation stopping at its next Step
+// should there be too many
+func (e *Evaluation) AllocateScope() {
+	if e != nil {
+		e.count(&e.scopes, 1, ScopesQuota, e.limits.Scopes)
+	}
+}
+
+func (e *Evaluation) count(counter *atomic.Int64, n int, quota Quota, limit int) error {
+	if limit > 0 && counter.Add(int64(n)) > int64(limit) {
+		err := &QuotaError{quota, limit}
+		e.exceeded.CompareAndSwap(nil, err)
+		e.done.Store(true)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"context"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_EvaluateContext_StopsWhenCancelled(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exp := EXPBuild(REF("+")).withArgs(I(1), I(2)).build()
+
+	//when
+	_, err := EvaluateContext(ctx, exp, env)
+	result, afterErr := Evaluate(exp, env)
+
+	//then
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.IsType(t, &EvalError{}, err)
+	assert.NoError(t, afterErr)
+	assert.Equal(t, I(3), result)
+}
+
+func Test_EvaluateContext_StopsIteratingLazyList(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	tail := EXPBuild(REF("range")).withArgs(I(1), I(1000000000)).build()
+	exp := EXPBuild(REF("apply")).withArgs(REF("+"), tail).build()
+
+	//when
+	_, err := EvaluateContext(ctx, exp, env)
+
+	//then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_Evaluation_NilWithoutContext(t *testing.T) {
+	//when
+	evaluation := EvaluationOf(NewEnvironment().NewChildScope())
+
+	//then
+	assert.Nil(t, evaluation)
+	assert.NoError(t, evaluation.Step())
+}
+
+func Test_Evaluation_StepsQuota(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.SetLimits(Limits{Steps: 10})
+	identity := EXPBuild(REF("fn")).withArgs(VEC{Vector: []interfaces.Type{REF("x")}}, REF("x")).build()
+	list := EXPBuild(REF("map")).withArgs(identity, EXPBuild(REF("range")).withArgs(I(1), I(100)).build()).build()
+	exp := EXPBuild(REF("apply")).withArgs(REF("+"), list).build()
+
+	//when
+	_, err := EvaluateContext(context.Background(), exp, env)
+	_, unlimitedErr := Evaluate(exp, env)
+
+	//then
+	var quotaErr *QuotaError
+	assert.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, &QuotaError{StepsQuota, 10}, quotaErr)
+	assert.NoError(t, unlimitedErr)
+}
+
+func Test_Evaluation_DepthQuotaIgnoresTailCalls(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.SetLimits(Limits{Depth: 5})
+	countdown := func(body interfaces.Type) *EXP {
+		return EXPBuild(REF("fn")).withArgs(
+			VEC{Vector: []interfaces.Type{REF("n")}},
+			EXPBuild(REF("if")).withArgs(EXPBuild(REF("=")).withArgs(REF("n"), I(0)).build(), I(0), body).build(),
+		).build()
+	}
+	decrement := EXPBuild(REF("-")).withArgs(REF("n"), I(1)).build()
+	tail := countdown(EXPBuild(REF("tail")).withArgs(decrement).build())
+	nonTail := countdown(EXPBuild(REF("+")).withArgs(I(1), EXPBuild(REF("nontail")).withArgs(decrement).build()).build())
+	_, err := Evaluate(EXPBuild(REF("do")).withArgs(
+		EXPBuild(REF("def")).withArgs(REF("tail"), tail).build(),
+		EXPBuild(REF("def")).withArgs(REF("nontail"), nonTail).build(),
+	).build(), env)
+	assert.NoError(t, err)
+
+	//when
+	result, tailErr := EvaluateContext(context.Background(), EXPBuild(REF("tail")).withArgs(I(1000)).build(), env)
+	_, nonTailErr := EvaluateContext(context.Background(), EXPBuild(REF("nontail")).withArgs(I(1000)).build(), env)
+
+	//then
+	assert.NoError(t, tailErr)
+	assert.Equal(t, I(0), result)
+	var quotaErr *QuotaError
+	assert.ErrorAs(t, nonTailErr, &quotaErr)
+	assert.Equal(t, &QuotaError{DepthQuota, 5}, quotaErr)
+}
+
+func Test_Evaluation_DefaultDepthWithoutLimits(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	decrement := EXPBuild(REF("-")).withArgs(REF("n"), I(1)).build()
+	nonTail := EXPBuild(REF("fn")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("n")}},
+		EXPBuild(REF("if")).withArgs(
+			EXPBuild(REF("=")).withArgs(REF("n"), I(0)).build(),
+			I(0),
+			EXPBuild(REF("+")).withArgs(I(1), EXPBuild(REF("nontail")).withArgs(decrement).build()).build(),
+		).build(),
+	).build()
+	_, err := Evaluate(EXPBuild(REF("def")).withArgs(REF("nontail"), nonTail).build(), env)
+	assert.NoError(t, err)
+
+	//when
+	result, withinErr := Evaluate(EXPBuild(REF("nontail")).withArgs(I(1000)).build(), env)
+	_, beyondErr := Evaluate(EXPBuild(REF("nontail")).withArgs(I(DefaultDepth*2)).build(), env)
+
+	//then
+	assert.NoError(t, withinErr)
+	assert.Equal(t, I(1000), result)
+	var quotaErr *QuotaError
+	assert.ErrorAs(t, beyondErr, &quotaErr)
+	assert.Equal(t, &QuotaError{DepthQuota, DefaultDepth}, quotaErr)
+}
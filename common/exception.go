@@ -0,0 +1,170 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+)
+
+// EXC is an exception, either a value raised by throw or an error returned by a builtin function, that try can catch.
+// Data is the value thrown, or for an error a MAP of :type :error and its :message.
+type EXC struct {
+	Message string
+	Data    interfaces.Value
+}
+
+// IsType for EXC
+func (e *EXC) IsType() {}
+
+// IsValue for EXC
+func (e *EXC) IsValue() {}
+
+// String representation of EXC
+func (e *EXC) String() string {
+	return fmt.Sprintf("EXC(%v)", e.Message)
+}
+
+// thrownError is the error returned by throw, holding the EXC that try catches
+type thrownError struct {
+	exc *EXC
+}
+
+func (t *thrownError) Error() string {
+	return t.exc.Message
+}
+
+// exceptionOf returns the EXC that err was raised by, or a new one describing an error from a builtin
+func exceptionOf(err error) *EXC {
+	var thrown *thrownError
+	if errors.As(err, &thrown) {
+		return thrown.exc
+	}
+	message := asEvalError(err).Err.Error()
+	data := &MAP{map[interfaces.Equalable]interfaces.Value{SYM(":type"): SYM(":error"), SYM(":message"): S(message)}, nil}
+	return &EXC{Message: message, Data: data}
+}
+
+// messageOf returns the message of a thrown value: the value itself if it is an S, the :message of a MAP, or else how
+// the value is printed
+func messageOf(value interfaces.Value, sco interfaces.Scope) string {
+	switch v := value.(type) {
+	case S:
+		return string(v)
+	case *MAP:
+		if message, ok := v.lookup(SYM(":message")); ok {
+			if s, ok := message.(S); ok {
+				return string(s)
+			}
+		}
+	}
+	return Sprint(value, sco)
+}
+
+func throw(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+	return NILL, &thrownError{&EXC{Message: messageOf(arguments[0], sco), Data: arguments[0]}}
+}
+
+// tryClauses splits the arguments of try into its body, the arguments of the catch clauses that follow and those of
+// an optional finally clause that ends it
+func tryClauses(arguments []interfaces.Type) (body []interfaces.Type, catches [][]interfaces.Type, finally []interfaces.Type, err error) {
+	ended := false
+	for _, arg := range arguments {
+		kind, clause := clauseOf(arg)
+		switch {
+		case ended:
+			return nil, nil, nil, fmt.Errorf("try : expected finally to be the last clause, recieved %v", arg)
+		case kind == "catch":
+			if _, ok := itemAfter(clause, 0).(REF); !ok || len(clause) != 3 {
+				return nil, nil, nil, fmt.Errorf("try : expected (catch pred name exp), recieved %v", arg)
+			}
+			catches = append(catches, clause)
+		case kind == "finally":
+			finally, ended = clause, true
+		case catches != nil:
+			return nil, nil, nil, fmt.Errorf("try : expected a catch or finally clause, recieved %v", arg)
+		default:
+			body = append(body, arg)
+		}
+	}
+	return body, catches, finally, nil
+}
+
+// clauseOf returns the kind and arguments of code such as (catch pred e exp), if it is an EXP
+func clauseOf(code interfaces.Type) (REF, []interfaces.Type) {
+	if exp, ok := code.(*EXP); ok {
+		kind, _ := nameOf(exp.Function)
+		return kind, exp.Arguments
+	}
+	return "", nil
+}
+
+// try evaluates its body, returning the value of the last expression. Should the body raise an exception, the handler
+// of the first catch clause whose predicate returns true when applied to it is evaluated instead, with the exception
+// bound to its name. The expressions of a finally clause are evaluated last whatever happens. Evaluation stopped by
+// its context or Limits cannot be caught.
+func try(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	body, catches, finally, err := tryClauses(arguments)
+	if err != nil {
+		return NILL, err
+	}
+	result, err := evaluateAll(body, sco)
+	if err != nil && EvaluationOf(sco).Err() == nil {
+		result, err = catch(catches, exceptionOf(err), err, sco)
+	}
+	if _, finallyErr := evaluateAll(finally, sco); finallyErr != nil {
+		return NILL, finallyErr
+	}
+	return result, err
+}
+
+// catch evaluates the handler of the first clause whose predicate is true for exc, or returns err if there is none
+func catch(catches [][]interfaces.Type, exc *EXC, err error, sco interfaces.Scope) (interfaces.Value, error) {
+	for _, c := range catches {
+		pred, predErr := evaluateToValue(c[0], sco)
+		if predErr != nil {
+			return NILL, predErr
+		}
+		caught, predErr := evaluateToValue(&EXP{Function: pred, Arguments: []interfaces.Type{exc}}, sco)
+		if predErr != nil {
+			return NILL, predErr
+		}
+		matched, ok := caught.(B)
+		if !ok {
+			return NILL, fmt.Errorf("catch : expected predicate to return B, recieved %v", caught)
+		}
+		if matched {
+			handlerScope := newLexicalScope(sco, 1)
+			handlerScope.CreateRef(c[1].(REF), exc)
+			return evaluateToValue(c[2], handlerScope)
+		}
+	}
+	return NILL, err
+}
+
+// evaluateAll evaluates each expression in turn, returning the value of the last or NIL if there are none
+func evaluateAll(code []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	var result interfaces.Value = NILL
+	for _, c := range code {
+		var err error
+		if result, err = evaluateToValue(c, sco); err != nil {
+			return NILL, err
+		}
+	}
+	return result, nil
+}
+
+func exMessage(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+	exc, ok := arguments[0].(*EXC)
+	if !ok {
+		return NILL, fmt.Errorf("ex-message : expected EXC, recieved %v", arguments[0])
+	}
+	return S(exc.Message), nil
+}
+
+func exData(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+	exc, ok := arguments[0].(*EXC)
+	if !ok {
+		return NILL, fmt.Errorf("ex-data : expected EXC, recieved %v", arguments[0])
+	}
+	return exc.Data, nil
+}
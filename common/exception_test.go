@@ -0,0 +1,97 @@
+package common
+
+import (
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// typeIs builds a predicate for catch that is true for exceptions whose data has the :type given
+func typeIs(kind SYM) FN {
+	data := EXPBuild(REF("ex-data")).withArgs(REF("e")).build()
+	typeOf := EXPBuild(REF("get")).withArgs(SYM(":type"), data).build()
+	return FNBuild().withArgs(REF("e")).withEXPBuilder(EXPBuild(REF("=")).withArgs(typeOf, kind)).build()
+}
+
+func catchOf(pred interfaces.Type, handler interfaces.Type) *EXP {
+	return EXPBuild(REF("catch")).withArgs(pred, REF("e"), handler).build()
+}
+
+func Test_try_CatchesThrownValue(t *testing.T) {
+	//given
+	thrown := EXPBuild(REF("hash-map")).withArgs(SYM(":type"), SYM(":bad"), SYM(":message"), S("boom")).build()
+	exp := EXPBuild(REF("try")).withArgs(
+		EXPBuild(REF("throw")).withArgs(thrown).build(),
+		catchOf(typeIs(":error"), I(1)),
+		catchOf(typeIs(":bad"), EXPBuild(REF("ex-message")).withArgs(REF("e")).build()),
+	).build()
+	//when
+	result, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, S("boom"), result)
+}
+
+func Test_try_CatchesErrorOfBuiltin(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("try")).withArgs(
+		EXPBuild(REF("+")).withArgs(I(1), SYM(":a")).build(),
+		catchOf(typeIs(":error"), EXPBuild(REF("ex-data")).withArgs(REF("e")).build()),
+	).build()
+	//when
+	result, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	data, ok := result.(*MAP)
+	if assert.True(t, ok) {
+		message, _ := data.lookup(SYM(":message"))
+		assert.Equal(t, S("numericFlatten : expected Numeric but argument 2 was :a"), message)
+	}
+}
+
+func Test_try_EvaluatesFinallyWhateverHappens(t *testing.T) {
+	//given
+	a := NewATOM(I(0))
+	finally := EXPBuild(REF("finally")).withArgs(EXPBuild(REF("swap!")).withArgs(a, REF("+"), I(1)).build()).build()
+	succeeds := EXPBuild(REF("try")).withArgs(I(5), finally).build()
+	fails := EXPBuild(REF("try")).withArgs(EXPBuild(REF("throw")).withArgs(S("oops")).build(), finally).build()
+	//when
+	result, err := Evaluate(succeeds, GlobalEnvironment)
+	_, failsErr := Evaluate(fails, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(5), result)
+	assert.EqualError(t, failsErr, "oops")
+	assert.Equal(t, I(2), a.Deref())
+}
+
+func Test_try_ReturnsErrorThatIsNotCaught(t *testing.T) {
+	//given
+	thrown := EXPBuild(REF("hash-map")).withArgs(SYM(":type"), SYM(":bad"), SYM(":message"), S("oops")).build()
+	exp := EXPBuild(REF("try")).withArgs(
+		EXPBuild(REF("throw")).withArgs(thrown).build(),
+		catchOf(typeIs(":error"), I(1)),
+	).build()
+	//when
+	_, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "oops")
+}
+
+func Test_try_ExpectsFinallyToBeTheLastClause(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("try")).withArgs(I(1), EXPBuild(REF("finally")).withArgs(I(2)).build(), I(3)).build()
+	//when
+	_, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "try : expected finally to be the last clause, recieved 3")
+}
+
+func Test_exMessage_ExpectsEXC(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("ex-message")).withArgs(I(1)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "ex-message : expected EXC, recieved 1")
+}
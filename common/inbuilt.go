@@ -9,42 +9,64 @@ import (
 type evaluator func([]interfaces.Value, interfaces.Scope) (interfaces.Value, error)
 type lazyEvaluator func([]interfaces.Type, interfaces.Scope) (interfaces.Value, error)
 
-var inbuilt map[REF]FI
+var inbuilt map[REF]interfaces.Value
 
 func init() {
-	inbuilt = map[REF]FI{}
+	inbuilt = map[REF]interfaces.Value{}
 	addInbuilt(FI{name: "=", evaluator: equals})
 	addInbuilt(FI{name: "+", evaluator: plusAll})
 	addInbuilt(FI{name: "-", evaluator: minusAll})
 	addInbuilt(FI{name: "*", evaluator: multiplyAll})
 	addInbuilt(FI{name: "%", evaluator: mod, argumentCount: 2})
-	addInbuilt(FI{name: "<", evaluator: lessThan})
-	addInbuilt(FI{name: ">", evaluator: greaterThan})
-	addInbuilt(FI{name: "<=", evaluator: lessThanEqual})
-	addInbuilt(FI{name: ">=", evaluator: greaterThanEqual})
+	addInbuilt(FI{name: "<", evaluator: lessThan, argumentCount: 2})
+	addInbuilt(FI{name: ">", evaluator: greaterThan, argumentCount: 2})
+	addInbuilt(FI{name: "<=", evaluator: lessThanEqual, argumentCount: 2})
+	addInbuilt(FI{name: ">=", evaluator: greaterThanEqual, argumentCount: 2})
 	addInbuilt(FI{name: "and", evaluator: and})
 	addInbuilt(FI{name: "assoc", evaluator: assoc})
-	addInbuilt(FI{name: "apply", lazyEvaluator: apply, argumentCount: 2})
+	addInbuilt(FI{name: "add-watch", evaluator: addWatch, argumentCount: 3})
+	addInbuilt(FI{name: "apply", lazyEvaluator: apply})
+	addInbuilt(FI{name: "atom", evaluator: atom, argumentCount: 1})
 	addInbuilt(FI{name: "cons", evaluator: cons})
+	addInbuilt(FI{name: "declare", lazyEvaluator: declare})
 	addInbuilt(FI{name: "def", lazyEvaluator: def, argumentCount: 2})
+	addInbuilt(FI{name: "deref", evaluator: deref, argumentCount: 1})
 	addInbuilt(FI{name: "do", lazyEvaluator: do})
 	addInbuilt(FI{name: "empty", evaluator: empty, argumentCount: 1})
+	addInbuilt(FI{name: "eval", evaluator: eval, argumentCount: 1})
 	addInbuilt(FI{name: "if", lazyEvaluator: iff, argumentCount: 3})
+	addInbuilt(FI{name: "ex-data", evaluator: exData, argumentCount: 1})
+	addInbuilt(FI{name: "ex-message", evaluator: exMessage, argumentCount: 1})
 	addInbuilt(FI{name: "filter", evaluator: filter, argumentCount: 2})
 	addInbuilt(FI{name: "first", evaluator: first, argumentCount: 1})
+	addInbuilt(FI{name: "gensym", evaluator: gensym})
 	addInbuilt(FI{name: "get", evaluator: get, argumentCount: 2})
-	addInbuilt(FI{name: "fn", lazyEvaluator: fn, argumentCount: 2})
+	addInbuilt(FI{name: "fn", lazyEvaluator: fn})
 	addInbuilt(FI{name: "hash-map", evaluator: hashmap})
 	addInbuilt(FI{name: "lazypair", lazyEvaluator: lazypair})
 	addInbuilt(FI{name: "let", lazyEvaluator: let, argumentCount: 2})
+	addInbuilt(FI{name: "letfn", lazyEvaluator: letfn, argumentCount: 2})
+	addInbuilt(FI{name: "loop", lazyEvaluator: loop, argumentCount: 2})
 	addInbuilt(FI{name: "macro", lazyEvaluator: macro, argumentCount: 2})
+	addInbuilt(FI{name: "macroexpand-1", evaluator: macroexpander(macroexpand1), argumentCount: 1})
+	addInbuilt(FI{name: "macroexpand", evaluator: macroexpander(MacroExpand), argumentCount: 1})
+	addInbuilt(FI{name: "macroexpand-all", evaluator: macroexpander(MacroExpandAll), argumentCount: 1})
 	addInbuilt(FI{name: "map", evaluator: mapp, argumentCount: 2})
 	addInbuilt(FI{name: "or", evaluator: or})
 	addInbuilt(FI{name: "print", evaluator: printt})
+	addInbuilt(FI{name: "quote", lazyEvaluator: quote, argumentCount: 1})
 	addInbuilt(FI{name: "panic", evaluator: panicc, argumentCount: 1})
 	addInbuilt(FI{name: "range", evaluator: rnge, argumentCount: 2})
+	addInbuilt(FI{name: "recur", lazyEvaluator: recur})
+	addInbuilt(FI{name: "reset!", evaluator: reset, argumentCount: 2})
+	addInbuilt(FI{name: "swap!", evaluator: swap})
+	addInbuilt(FI{name: "syntax-quote", lazyEvaluator: syntaxQuote, argumentCount: 1})
 	addInbuilt(FI{name: "tail", evaluator: tail, argumentCount: 1})
 	addInbuilt(FI{name: "take", evaluator: take, argumentCount: 2})
+	addInbuilt(FI{name: "throw", evaluator: throw, argumentCount: 1})
+	addInbuilt(FI{name: "try", lazyEvaluator: try})
+	addInbuilt(FI{name: "unquote", lazyEvaluator: unquote, argumentCount: 1})
+	addInbuilt(FI{name: "unquote-splicing", lazyEvaluator: unquoteSplicing, argumentCount: 1})
 }
 
 func addInbuilt(info FI) {
@@ -52,19 +74,19 @@ func addInbuilt(info FI) {
 }
 
 func plusAll(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
-	return numericFlatten(arguments, func(a interfaces.Numeric, b interfaces.Numeric) interfaces.Numeric {
+	return numericFlatten(arguments, func(a interfaces.Numeric, b interfaces.Numeric) (interfaces.Numeric, error) {
 		return a.Add(b)
 	})
 }
 
 func minusAll(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
-	return numericFlatten(arguments, func(a interfaces.Numeric, b interfaces.Numeric) interfaces.Numeric {
+	return numericFlatten(arguments, func(a interfaces.Numeric, b interfaces.Numeric) (interfaces.Numeric, error) {
 		return a.Subtract(b)
 	})
 }
 
 func multiplyAll(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
-	return numericFlatten(arguments, func(a interfaces.Numeric, b interfaces.Numeric) interfaces.Numeric {
+	return numericFlatten(arguments, func(a interfaces.Numeric, b interfaces.Numeric) (interfaces.Numeric, error) {
 		return a.Multiply(b)
 	})
 }
@@ -73,19 +95,27 @@ func mod(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, er
 	a, aok := arguments[0].(I)
 	b, bok := arguments[1].(I)
 	if aok && bok {
-		return a.Mod(b), nil
+		return a.Mod(b)
 	}
 	return NILL, errors.New("mod : unsupported type")
 }
 
+// equals returns true if each argument is equal to the one following it
 func equals(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
-	first, fok := arguments[0].(interfaces.Equalable)
-	second, sok := arguments[1].(interfaces.Equalable)
-
-	if fok && sok {
-		return first.Equals(second), nil
+	if len(arguments) == 0 {
+		return NILL, errors.New("Equals : invalid number of arguments [0 of at least 1]")
 	}
-	return NILL, fmt.Errorf("Equals : unsupported type %v or %v", arguments[0], arguments[1])
+	for i := 1; i < len(arguments); i++ {
+		first, fok := arguments[i-1].(interfaces.Equalable)
+		second, sok := arguments[i].(interfaces.Equalable)
+		if !fok || !sok {
+			return NILL, fmt.Errorf("Equals : unsupported type %v or %v", arguments[i-1], arguments[i])
+		}
+		if first.Equals(second) != B(true) {
+			return B(false), nil
+		}
+	}
+	return B(true), nil
 }
 
 func lessThan(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
@@ -143,14 +173,14 @@ func greaterThanEqual(arguments []interfaces.Value, _ interfaces.Scope) (interfa
 	return NILL, fmt.Errorf("greaterThanEqual : unsupported type %v or %v", arguments[0], arguments[1])
 }
 
-func cons(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+func cons(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+	EvaluationOf(sco).Allocate(1)
 	if len(arguments) == 0 {
 		return ENDED, nil
 	} else if len(arguments) == 1 {
 		return P{arguments[0], ENDED}, nil
 	} else if len(arguments) == 2 {
-		tail, ok := arguments[1].(P)
-		if ok {
+		if tail, ok := arguments[1].(interfaces.Iterable); ok {
 			return P{arguments[0], tail}, nil
 		}
 	}
@@ -176,23 +206,46 @@ func tail(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value,
 	return NILL, fmt.Errorf("tail : %v is not of type Iterable", arguments[0])
 }
 
+// apply applies a function to the items of a list, preceded by any other arguments given
 func apply(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
-	list, err := evaluateToValue(arguments[1], sco)
+	if len(arguments) < 2 {
+		return NILL, fmt.Errorf("apply : invalid number of arguments [%d of at least 2]", len(arguments))
+	}
+	function := arguments[0]
+	if _, ok := function.(reference); !ok {
+		value, err := evaluateToValue(function, sco)
+		if err != nil {
+			return NILL, err
+		}
+		_, isFunction := value.(interfaces.Appliable)
+		_, isMacro := value.(interfaces.Expandable)
+		if !isFunction && !isMacro {
+			return NILL, fmt.Errorf("apply : expected function, found %v", arguments[0])
+		}
+		function = value
+	}
+	last := len(arguments) - 1
+	args := make([]interfaces.Type, 0, last)
+	for _, arg := range arguments[1:last] {
+		value, err := evaluateToValue(arg, sco)
+		if err != nil {
+			return NILL, err
+		}
+		args = append(args, value)
+	}
+	list, err := evaluateToValue(arguments[last], sco)
 	if err != nil {
 		return NILL, err
 	}
-	s, okRef := arguments[0].(REF)
-	p, okPair := list.(interfaces.Sliceable)
-	if !okRef {
-		return NILL, fmt.Errorf("apply : expected function, found %v", arguments[0])
-	} else if !okPair {
+	p, ok := list.(interfaces.Sliceable)
+	if !ok {
 		return NILL, fmt.Errorf("apply : expected pair, found %v", list)
 	}
-	slice, err := p.ToSlice(sco.NewChildScope())
+	slice, err := p.ToSlice(sco)
 	if err != nil {
 		return NILL, err
 	}
-	return evaluateToValue(&EXP{Function: s, Arguments: slice}, sco)
+	return evaluateTail(&EXP{Function: function, Arguments: append(args, slice...)}, sco)
 }
 
 func iff(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
@@ -202,57 +255,174 @@ func iff(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, e
 	}
 	if iff, iok := test.(B); iok {
 		if iff {
-			return evaluateToValue(arguments[1], sco)
+			return evaluateTail(arguments[1], sco)
 		}
-		return evaluateToValue(arguments[2], sco)
+		return evaluateTail(arguments[2], sco)
 	}
 	return NILL, fmt.Errorf("if : expected first argument to evaluate to boolean, recieved %v", test)
 }
 
+// def binds a variable in the fn, let or loop it is evaluated within, or in the global Environment when it is not
+// within any
 func def(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	name, ok := arguments[0].(REF)
+	if !ok {
+		return NILL, fmt.Errorf("def : expected a name, recieved %v", arguments[0])
+	}
 	value, err := evaluateToValue(arguments[1], sco)
 	if err != nil {
 		return NILL, err
 	}
-	GlobalEnvironment.CreateRef(arguments[0].(REF), value)
+	if local, ok := sco.(*lexicalScope); ok {
+		local.CreateRef(name, value)
+	} else {
+		GlobalOf(sco).CreateRef(name, value)
+	}
+	return NILL, nil
+}
+
+// declare creates each named variable in the global Environment that does not yet exist there, so that it can be
+// referred to before it is defined
+func declare(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	global := GlobalOf(sco)
+	for _, arg := range arguments {
+		name, ok := arg.(REF)
+		if !ok {
+			return NILL, fmt.Errorf("declare : expected names, recieved %v", arg)
+		}
+		global.declare(name)
+	}
 	return NILL, nil
 }
 
 func do(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
-	var result interfaces.Value
-	for _, a := range arguments {
-		next, err := evaluateToValue(a, sco.NewChildScope())
-		if err != nil {
+	if len(arguments) == 0 {
+		return NILL, nil
+	}
+	last := len(arguments) - 1
+	for _, a := range arguments[:last] {
+		if _, err := evaluateToValue(a, sco); err != nil {
 			return NILL, err
 		}
-		result = next
 	}
-	return result, nil
+	return evaluateTail(arguments[last], sco)
 }
 
 func rnge(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
-	start := arguments[0].(I)
-	end := arguments[1].(I)
+	start, sok := arguments[0].(I)
+	end, eok := arguments[1].(I)
+	if !sok || !eok {
+		return NILL, fmt.Errorf("range : expected start and end to be I, recieved %v and %v", arguments[0], arguments[1])
+	}
 	if start < end {
-		return createLAZYP(sco, start, REF("range"), I(start.Int()+1), end), nil
+		return createLAZYP(sco, start, "range", rnge, I(start.Int()+1), end), nil
 	}
 	return P{end, ENDED}, nil
 
 }
 
 func fn(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
-	var argVec VEC
-	if args, ok := arguments[0].(REF); ok {
-		arg, err := args.Evaluate(sco)
+	if len(arguments) > 0 {
+		if _, ok := arguments[0].(*EXP); ok {
+			return multiArityFn(arguments, sco)
+		}
+	}
+	if len(arguments) != 2 {
+		return NILL, fmt.Errorf("fn : invalid number of arguments [%d of 2]", len(arguments))
+	}
+	var args interfaces.Type = arguments[0]
+	if ref, ok := args.(REF); ok {
+		var err error
+		if args, err = ref.Evaluate(sco); err != nil {
+			return NILL, err
+		}
+	}
+	argVec, ok := args.(VEC)
+	if !ok {
+		return NILL, fmt.Errorf("fn : expected VEC of arguments, recieved %v", args)
+	}
+	if _, _, err := Parameters(argVec); err != nil {
+		return NILL, fmt.Errorf("fn : %v", err)
+	}
+	body, ok := arguments[1].(interfaces.Evaluatable)
+	if !ok {
+		return NILL, fmt.Errorf("fn : expected the body to be an expression, recieved %v", arguments[1])
+	}
+	if err := checkRecur(arguments[1], -1, true, sco); err != nil {
+		return NILL, err
+	}
+	return FN{Arguments: argVec, Expression: body, Scope: sco}, nil
+}
+
+// multiArityFn creates an FN from arities such as ([x] exp) and ([x y] exp), each accepting a different number of
+// arguments. Only one may be variadic.
+func multiArityFn(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	arities := make([]FN, len(arguments))
+	fixed := map[int]bool{}
+	variadic := false
+	for i, arg := range arguments {
+		exp, ok := arg.(*EXP)
+		if !ok || len(exp.Arguments) != 1 {
+			return NILL, fmt.Errorf("fn : expected arities of the form ([args] exp), recieved %v", arg)
+		}
+		argVec, ok := exp.Function.(VEC)
+		if !ok {
+			return NILL, fmt.Errorf("fn : expected arities of the form ([args] exp), recieved %v", arg)
+		}
+		body, ok := exp.Arguments[0].(interfaces.Evaluatable)
+		if !ok {
+			return NILL, fmt.Errorf("fn : expected the body of %v to be an expression, recieved %v", arg, exp.Arguments[0])
+		}
+		arities[i] = FN{Arguments: argVec, Expression: body, Scope: sco}
+		required, more, err := arityOf(arities[i])
 		if err != nil {
+			return NILL, fmt.Errorf("fn : %v", err)
+		}
+		if more && variadic {
+			return NILL, errors.New("fn : expected at most one variadic arity")
+		} else if !more && fixed[required] {
+			return NILL, fmt.Errorf("fn : expected a single arity accepting %d arguments", required)
+		}
+		variadic = variadic || more
+		fixed[required] = fixed[required] || !more
+		if err := checkRecur(exp.Arguments[0], -1, true, sco); err != nil {
 			return NILL, err
 		}
-		argVec = arg.(VEC)
-	} else {
-		argVec = arguments[0].(VEC)
 	}
+	return FN{Scope: sco, Arities: arities}, nil
+}
 
-	return FN{argVec, arguments[1].(interfaces.Evaluatable)}, nil
+// letfn binds functions, defined as (name [args] exp) or (name ([args] exp)...), in a new scope in which exp is then
+// evaluated. Each function is created within that scope so they may call themselves and each other.
+func letfn(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	definitions, vok := arguments[0].(VEC)
+	_, eok := arguments[1].(interfaces.Evaluatable)
+	if !vok || !eok {
+		return NILL, fmt.Errorf("letfn : expected VEC and EXP, received: %v, %v", arguments[0], arguments[1])
+	}
+	childScope := newLexicalScope(sco, len(definitions.Vector))
+	for _, definition := range definitions.Vector {
+		name, ok := letfnName(definition)
+		if !ok {
+			return NILL, fmt.Errorf("letfn : expected functions of the form (name [args] exp), recieved %v", definition)
+		}
+		function, err := fn(definition.(*EXP).Arguments, childScope)
+		if err != nil {
+			return NILL, err
+		}
+		childScope.CreateRef(name, function)
+	}
+	return evaluateTail(arguments[1], childScope)
+}
+
+// letfnName returns the name of a function defined within letfn
+func letfnName(definition interfaces.Type) (REF, bool) {
+	exp, ok := definition.(*EXP)
+	if !ok {
+		return "", false
+	}
+	name, ok := exp.Function.(REF)
+	return name, ok
 }
 
 func filter(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
@@ -262,7 +432,7 @@ func filter(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Valu
 	var flt func(interfaces.Iterable) (interfaces.Iterable, error)
 	flt = func(it interfaces.Iterable) (interfaces.Iterable, error) {
 		head := it.Head()
-		res, err := evaluateToValue(&EXP{Function: ap, Arguments: []interfaces.Type{head}}, sco.NewChildScope())
+		res, err := evaluateToValue(&EXP{Function: ap, Arguments: []interfaces.Type{head}}, sco)
 		if err != nil {
 			return ENDED, err
 		}
@@ -273,7 +443,7 @@ func filter(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Valu
 					return ENDED, err
 				}
 				if bool(include) {
-					return createLAZYP(sco, head, REF("filter"), ap, next), nil
+					return createLAZYP(sco, head, "filter", filter, ap, next), nil
 				}
 				return flt(next)
 			}
@@ -297,14 +467,14 @@ func mapp(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value,
 
 	if fnok && lok {
 		head := list.Head()
-		res, err := evaluateToValue(&EXP{Function: fn, Arguments: []interfaces.Type{head}}, sco.NewChildScope())
+		res, err := evaluateToValue(&EXP{Function: fn, Arguments: []interfaces.Type{head}}, sco)
 		if err == nil {
 			if !list.HasTail() {
 				return &P{res, ENDED}, nil
 			}
 			next, err := list.Iterate(sco)
 			if err == nil {
-				return createLAZYP(sco, res, REF("map"), fn, next), nil
+				return createLAZYP(sco, res, "map", mapp, fn, next), nil
 			}
 		}
 		return ENDED, err
@@ -313,6 +483,10 @@ func mapp(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value,
 }
 
 func lazypair(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	if len(arguments) == 0 || len(arguments) > 2 {
+		return NILL, fmt.Errorf("lazypair : invalid number of arguments [%d of 1 or 2]", len(arguments))
+	}
+	EvaluationOf(sco).Allocate(1)
 	head, err := evaluateToValue(arguments[0], sco)
 	if err != nil {
 		return NILL, err
@@ -326,8 +500,15 @@ func lazypair(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Val
 	return LAZYP{head, nil}, nil
 }
 
-func macro(arguments []interfaces.Type, _ interfaces.Scope) (interfaces.Value, error) {
-	return MAC{arguments[0].(VEC), arguments[1].(*EXP)}, nil
+func macro(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	args, ok := arguments[0].(VEC)
+	if !ok {
+		return NILL, fmt.Errorf("macro : expected VEC of arguments, recieved %v", arguments[0])
+	}
+	if _, _, err := Parameters(args); err != nil {
+		return NILL, fmt.Errorf("macro : %v", err)
+	}
+	return MAC{Arguments: args, Expression: arguments[1], Scope: sco}, nil
 }
 
 func printt(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
@@ -358,7 +539,7 @@ func take(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value,
 			if err != nil {
 				return NILL, err
 			}
-			return createLAZYP(sco, list.Head(), REF("take"), num-1, next), nil
+			return createLAZYP(sco, list.Head(), "take", take, num-1, next), nil
 		}
 		return P{list.Head(), ENDED}, nil
 
@@ -368,29 +549,89 @@ func take(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value,
 
 func let(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
 	vectors, vok := arguments[0].(VEC)
-	exp, eok := arguments[1].(interfaces.Evaluatable)
-
-	childScope := sco.NewChildScope()
+	_, eok := arguments[1].(interfaces.Evaluatable)
 
 	if vok && eok {
 		count := vectors.count()
 		if count%2 > 0 {
 			return NILL, fmt.Errorf("let : expected an even number of items in vector, recieved %v", count)
 		}
+		childScope := newLexicalScope(sco, count/2)
 		for i := 0; i < count; i += 2 {
 			val, err := evaluateToValue(vectors.Get(i+1), childScope)
 			if err != nil {
 				return NILL, err
 			}
-			childScope.CreateRef(vectors.Get(i), val)
+			if err := bind(vectors.Get(i), val, childScope, childScope); err != nil {
+				return NILL, fmt.Errorf("let : %v", err)
+			}
 		}
-		return exp.Evaluate(childScope)
+		return evaluateTail(arguments[1], childScope)
 	}
 	return NILL, fmt.Errorf("let : expected VEC and EXP, received: %v, %v", arguments[0], arguments[1])
 }
 
+func loop(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	vectors, vok := arguments[0].(VEC)
+	_, eok := arguments[1].(interfaces.Evaluatable)
+	if !vok || !eok {
+		return NILL, fmt.Errorf("loop : expected VEC and EXP, received: %v, %v", arguments[0], arguments[1])
+	}
+	count := vectors.count()
+	if count%2 > 0 {
+		return NILL, fmt.Errorf("loop : expected an even number of items in vector, recieved %v", count)
+	}
+	if err := checkRecur(arguments[1], count/2, true, sco); err != nil {
+		return NILL, err
+	}
+
+	loopScope := newLexicalScope(sco, count/2)
+	for i := 0; i < count; i += 2 {
+		val, err := evaluateToValue(vectors.Get(i+1), loopScope)
+		if err != nil {
+			return NILL, err
+		}
+		if err := bind(vectors.Get(i), val, loopScope, loopScope); err != nil {
+			return NILL, fmt.Errorf("loop : %v", err)
+		}
+	}
+	for {
+		result, err := bounce(evaluateTail(arguments[1], loopScope))
+		if err != nil {
+			return NILL, err
+		}
+		rec, ok := result.(*recurValue)
+		if !ok {
+			return result, nil
+		}
+		if len(rec.values) != count/2 {
+			return NILL, fmt.Errorf("recur : expected %d arguments, recieved %d", count/2, len(rec.values))
+		}
+		previous := loopScope
+		loopScope = newLexicalScope(sco, count/2)
+		for i, val := range rec.values {
+			if err := bind(vectors.Get(i*2), val, loopScope, previous); err != nil {
+				return NILL, fmt.Errorf("loop : %v", err)
+			}
+		}
+	}
+}
+
+func recur(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	values := make([]interfaces.Value, len(arguments))
+	for p, arg := range arguments {
+		var err error
+		values[p], err = evaluateToValue(arg, sco)
+		if err != nil {
+			return NILL, err
+		}
+	}
+	return &recurValue{values}, nil
+}
+
+// panicc stops evaluation with a message
 func panicc(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
-	panic(arguments[0].String())
+	return NILL, fmt.Errorf("panic : %v", arguments[0])
 }
 
 func hashmap(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
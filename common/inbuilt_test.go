@@ -38,6 +38,16 @@ func Test_equals_ErrorsIfTypFesNotValid(t *testing.T) {
 	assert.EqualError(t, err, "Equals : unsupported type P(<nil> <nil>) or 1")
 }
 
+func Test_equals_ComparesEachArgumentToTheNext(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("=")).withArgs(I(1), I(1), I(2)).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, B(false), result)
+}
+
 // cons
 
 func Test_cons_CreatesPairWithNil(t *testing.T) {
@@ -62,6 +72,16 @@ func Test_cons_CreatesPairWithTailPair(t *testing.T) {
 	assert.False(t, result.(P).tail.HasTail())
 }
 
+func Test_cons_CreatesPairWithEmptyTail(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("cons")).withArgs(I(1), ENDED).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{I(1), ENDED}, result)
+}
+
 // first
 
 func Test_first_RetrievesHeadOfPair(t *testing.T) {
@@ -155,7 +175,7 @@ func Test_apply_InvalidNumberOfArguments(t *testing.T) {
 	result, err := exp.Evaluate(GlobalEnvironment)
 	//then
 	assert.Equal(t, NILL, result)
-	assert.EqualError(t, err, "apply : invalid number of arguments [0 of 2]")
+	assert.EqualError(t, err, "apply : invalid number of arguments [0 of at least 2]")
 }
 
 // filter
@@ -290,6 +310,15 @@ func Test_def_RecordsReferences(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func Test_def_ExpectsName(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("def")).withArgs(I(1), I(2)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "def : expected a name, recieved 1")
+}
+
 // do
 
 func Test_do_ReturnsLastArgument(t *testing.T) {
@@ -316,6 +345,15 @@ func Test_range_ReturnsLazyPair(t *testing.T) {
 	assert.NotNil(t, lazyp)
 }
 
+func Test_range_ExpectsIntegers(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("range")).withArgs(I(1), S("10")).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "range : expected start and end to be I, recieved 1 and 10")
+}
+
 // multiply *
 
 func Test_multiply_TwoIntegers(t *testing.T) {
@@ -400,6 +438,15 @@ func Test_mod_IncorrectNumberOfArguments(t *testing.T) {
 	assert.EqualError(t, err, "% : invalid number of arguments [1 of 2]")
 }
 
+func Test_mod_Zero(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("%")).withArgs(I(7), I(0)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "Mod : cannot find 7 modulo zero")
+}
+
 // lessThan <
 
 func Test_lessThan_IntegersFirstIsHigher(t *testing.T) {
@@ -486,6 +533,16 @@ func Test_greaterThan_UnsupportedType(t *testing.T) {
 
 // lessThanEqual <=
 
+func Test_lessThan_Strings(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("<")).withArgs(S("apple"), S("banana")).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, B(true), result)
+}
+
 func Test_lessThanEqual_IntegersFirstIsHigher(t *testing.T) {
 	//given
 	exp := EXPBuild(REF("<=")).withArgs(I(6), I(1)).build()
@@ -676,7 +733,7 @@ func Test_let_ExpectsVectorAndExpression(t *testing.T) {
 func Test_let_ExpectsEvenNumberSizedVector(t *testing.T) {
 	//given
 	exp := EXPBuild(REF("let")).withArgs(
-		VEC{[]interfaces.Type{REF("a")}},
+		VEC{Vector: []interfaces.Type{REF("a")}},
 		&EXP{}).build()
 	//when
 	result, err := exp.Evaluate(GlobalEnvironment)
@@ -688,7 +745,7 @@ func Test_let_ExpectsEvenNumberSizedVector(t *testing.T) {
 func Test_let_CanUseVariableDefinedInLetWithinLet(t *testing.T) {
 	//given
 	exp := EXPBuild(REF("let")).withArgs(
-		VEC{[]interfaces.Type{
+		VEC{Vector: []interfaces.Type{
 			REF("a"), I(1),
 			REF("b"), REF("a"),
 		}},
@@ -700,15 +757,237 @@ func Test_let_CanUseVariableDefinedInLetWithinLet(t *testing.T) {
 	assert.Equal(t, I(1), result)
 }
 
+// loop
+
+func Test_loop_ReturnsBodyWhenNotRecurring(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("a"), I(1)}},
+		EXPBuild(REF("+")).withArgs(REF("a"), I(1)).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(2), result)
+}
+
+func Test_loop_RecurRebindsValues(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("i"), I(0), REF("acc"), I(1)}},
+		EXPBuild(REF("if")).withArgs(
+			EXPBuild(REF("<")).withArgs(REF("i"), I(5)).build(),
+			EXPBuild(REF("recur")).withArgs(
+				EXPBuild(REF("+")).withArgs(REF("i"), I(1)).build(),
+				EXPBuild(REF("*")).withArgs(REF("acc"), I(2)).build(),
+			).build(),
+			REF("acc"),
+		).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(32), result)
+}
+
+func Test_loop_ExpectsVectorAndExpression(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(B(true), B(false)).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "loop : expected VEC and EXP, received: true, false")
+}
+
+func Test_loop_ExpectsEvenNumberSizedVector(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(VEC{Vector: []interfaces.Type{REF("a")}}, REF("a")).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "loop : expected an even number of items in vector, recieved 1")
+}
+
+// recur
+
+func Test_recur_RejectsWrongNumberOfArguments(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("i"), I(0)}},
+		EXPBuild(REF("recur")).withArgs(I(1), I(2)).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "recur : expected 1 arguments, recieved 2")
+}
+
+func Test_recur_RejectsNonTailPosition(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("i"), I(0)}},
+		EXPBuild(REF("+")).withArgs(I(1), EXPBuild(REF("recur")).withArgs(I(1)).build()).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "recur : can only be used in tail position of loop")
+}
+
+func Test_recur_RejectsTestOfIf(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("i"), I(0)}},
+		EXPBuild(REF("if")).withArgs(EXPBuild(REF("recur")).withArgs(I(1)).build(), I(1), I(2)).build(),
+	).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "recur : can only be used in tail position of loop")
+}
+
+func Test_recur_AcceptedInTailPositionOfMacroExpansion(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.CreateRef(REF("unless"), MAC{
+		Arguments: VEC{Vector: []interfaces.Type{REF("c"), REF("a"), REF("b")}},
+		Expression: EXPBuild(REF("syntax-quote")).withArgs(EXPBuild(REF("if")).withArgs(
+			EXPBuild(REF("unquote")).withArgs(REF("c")).build(),
+			EXPBuild(REF("unquote")).withArgs(REF("b")).build(),
+			EXPBuild(REF("unquote")).withArgs(REF("a")).build(),
+		).build()).build(),
+	})
+	exp := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("i"), I(0)}},
+		EXPBuild(REF("unless")).withArgs(
+			EXPBuild(REF("<")).withArgs(REF("i"), I(3)).build(),
+			REF("i"),
+			EXPBuild(REF("recur")).withArgs(EXPBuild(REF("+")).withArgs(REF("i"), I(1)).build()).build(),
+		).build(),
+	).build()
+	notTail := EXPBuild(REF("loop")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("i"), I(0)}},
+		EXPBuild(REF("unless")).withArgs(
+			EXPBuild(REF("recur")).withArgs(I(1)).build(),
+			REF("i"),
+			I(2),
+		).build(),
+	).build()
+	//when
+	result, err := Evaluate(exp, env)
+	_, notTailErr := Evaluate(notTail, env)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(3), result)
+	assert.EqualError(t, notTailErr, "recur : can only be used in tail position of loop")
+}
+
+func Test_recur_RejectedOutsideOfLoop(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("recur")).withArgs(I(1)).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "recur : can only be used in tail position of loop")
+}
+
+func Test_recur_RejectedWithinFn(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("fn")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("a")}},
+		EXPBuild(REF("recur")).withArgs(REF("a")).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "recur : can only be used within loop")
+}
+
+func Test_fn_RejectsTwoAritiesAcceptingSameNumberOfArguments(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("fn")).withArgs(
+		EXPBuild(VEC{Vector: []interfaces.Type{REF("a")}}).withArgs(REF("a")).build(),
+		EXPBuild(VEC{Vector: []interfaces.Type{REF("b")}}).withArgs(REF("b")).build(),
+	).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.Equal(t, NILL, result)
+	assert.EqualError(t, err, "fn : expected a single arity accepting 1 arguments")
+}
+
+func Test_fn_ExpectsExpressionAsBody(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("fn")).withArgs(VEC{Vector: []interfaces.Type{REF("a")}}, I(1)).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "fn : expected the body to be an expression, recieved 1")
+}
+
+// letfn
+
+func Test_letfn_FunctionsCanCallEachOther(t *testing.T) {
+	//given
+	n := VEC{Vector: []interfaces.Type{REF("n")}}
+	even := EXPBuild(REF("ev")).withArgs(n, EXPBuild(REF("if")).withArgs(
+		EXPBuild(REF("=")).withArgs(REF("n"), I(0)).build(), B(true), EXPBuild(REF("od")).withArgs(EXPBuild(REF("-")).withArgs(REF("n"), I(1)).build()).build()).build()).build()
+	odd := EXPBuild(REF("od")).withArgs(n, EXPBuild(REF("if")).withArgs(
+		EXPBuild(REF("=")).withArgs(REF("n"), I(0)).build(), B(false), EXPBuild(REF("ev")).withArgs(EXPBuild(REF("-")).withArgs(REF("n"), I(1)).build()).build()).build()).build()
+	exp := EXPBuild(REF("letfn")).withArgs(VEC{Vector: []interfaces.Type{even, odd}}, EXPBuild(REF("od")).withArgs(I(7)).build()).build()
+	//when
+	result, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, B(true), result)
+	_, defined := GlobalEnvironment.ResolveRef(REF("od"))
+	assert.False(t, defined)
+}
+
+func Test_letfn_ExpectsFunctionDefinitions(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("letfn")).withArgs(VEC{Vector: []interfaces.Type{I(1)}}, REF("a")).build()
+	//when
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "letfn : expected functions of the form (name [args] exp), recieved 1")
+}
+
+// declare
+
+func Test_declare_CreatesUndefinedVariablesOnly(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.CreateRef(REF("defined"), I(1))
+	exp := EXPBuild(REF("declare")).withArgs(REF("defined"), REF("later")).build()
+	//when
+	_, err := exp.Evaluate(newLexicalScope(env, 0))
+	//then
+	assert.NoError(t, err)
+	defined, _ := env.ResolveRef(REF("defined"))
+	assert.Equal(t, I(1), defined)
+	later, ok := env.ResolveRef(REF("later"))
+	assert.True(t, ok)
+	assert.Equal(t, NILL, later)
+}
+
 // panic
 
-func Test_panic_PanicsWithMessage(t *testing.T) {
+func Test_panic_ReturnsErrorWithMessage(t *testing.T) {
 	//given
 	exp := EXPBuild(REF("panic")).withArgs(S("a message")).build()
 	//when
-	assert.Panics(t, func() {
-		_, _ = exp.Evaluate(GlobalEnvironment)
-	}, "Expected panic")
+	_, err := exp.Evaluate(GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "panic : a message")
 }
 
 //assoc
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mikeyhu/glipso/interfaces"
 )
@@ -59,6 +60,15 @@ func (p P) ToSlice(sco interfaces.Scope) ([]interfaces.Type, error) {
 	}
 }
 
+// NewList creates a list of items, or ENDED if there are none
+func NewList(items []interfaces.Value) interfaces.Iterable {
+	var list interfaces.Iterable = ENDED
+	for i := len(items) - 1; i >= 0; i-- {
+		list = P{items[i], list}
+	}
+	return list
+}
+
 // LAZYP (Lazily evaluated Pair)
 // head operates like Pair, tail should be an expression that returns another LAZYP
 type LAZYP struct {
@@ -87,9 +97,19 @@ func (l LAZYP) HasTail() bool {
 	return l.tail != nil
 }
 
-// Iterate will evaluate the tail of the LAZYP
+// Iterate will evaluate the tail of the LAZYP. Each element produced counts as a step of the evaluation, as inbuilt
+// functions such as range produce them without evaluating anything else, and counts towards its Depth as it may
+// iterate other LAZYPs in turn.
 func (l LAZYP) Iterate(sco interfaces.Scope) (interfaces.Iterable, error) {
+	evaluation := EvaluationOf(sco)
+	if err := evaluation.Step(); err != nil {
+		return ENDED, err
+	}
+	if err := evaluation.Enter(); err != nil {
+		return ENDED, err
+	}
 	taileval, err := l.tail.Evaluate(sco)
+	evaluation.Leave(1)
 	if err != nil {
 		return ENDED, err
 	}
@@ -109,7 +129,7 @@ func (l LAZYP) ToSlice(sco interfaces.Scope) ([]interfaces.Type, error) {
 		if !next.HasTail() {
 			return slice, nil
 		}
-		res, err := next.Iterate(sco.NewChildScope())
+		res, err := next.Iterate(sco)
 		if err != nil {
 			return slice, err
 		}
@@ -118,10 +138,34 @@ func (l LAZYP) ToSlice(sco interfaces.Scope) ([]interfaces.Type, error) {
 	}
 }
 
-func createLAZYP(sco interfaces.Scope, head interfaces.Value, function interfaces.Type, args ...interfaces.Type) LAZYP {
-	return LAZYP{
-		head,
-		BindEvaluation(&EXP{function, args}, sco)}
+// NewLAZYP creates a LAZYP from a head and an Evaluatable that will return the tail, a nil tail ends the list
+func NewLAZYP(head interfaces.Value, tail interfaces.Evaluatable) LAZYP {
+	return LAZYP{head, tail}
+}
+
+// createLAZYP creates a LAZYP for an inbuilt function, its tail applies the evaluator to the arguments when iterated
+func createLAZYP(sco interfaces.Scope, head interfaces.Value, name string, ev evaluator, first interfaces.Value, second interfaces.Value) LAZYP {
+	EvaluationOf(sco).Allocate(1)
+	return LAZYP{head, &lazyTail{name, ev, [2]interfaces.Value{first, second}, sco}}
+}
+
+// lazyTail is the tail of a LAZYP created by an inbuilt function of two arguments
+type lazyTail struct {
+	name      string
+	evaluator evaluator
+	arguments [2]interfaces.Value
+	scope     interfaces.Scope
+}
+
+// Evaluate applies the evaluator to the arguments within the scope the LAZYP was created in, under the Evaluation of
+// the scope provided
+func (t *lazyTail) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
+	return t.evaluator(t.arguments[:], Within(t.scope, EvaluationOf(sco)))
+}
+
+// String representation of lazyTail
+func (t *lazyTail) String() string {
+	return fmt.Sprintf("(%s %v %v)", t.name, t.arguments[0], t.arguments[1])
 }
 
 // END acts as the end of a list
@@ -148,9 +192,9 @@ func (e END) HasTail() bool {
 	return false
 }
 
-// Iterate on END will panic
+// Iterate on END returns an error, as there is nothing after it
 func (e END) Iterate(interfaces.Scope) (interfaces.Iterable, error) {
-	panic("END : Iterate called on END")
+	return ENDED, errors.New("END : Iterate called on END")
 }
 
 // ToSlice returns an empty slice
@@ -18,7 +18,7 @@ func Test_Pair_IterateToTail(t *testing.T) {
 }
 
 func Test_Pair_TailNotIterable(t *testing.T) {
-	pair := LAZYP{I(1), &EXP{REF("+"), []interfaces.Type{I(2)}}}
+	pair := LAZYP{I(1), &EXP{Function: REF("+"), Arguments: []interfaces.Type{I(2)}}}
 
 	assert.Equal(t, I(1), pair.Head())
 	assert.True(t, pair.HasTail())
@@ -27,3 +27,9 @@ func Test_Pair_TailNotIterable(t *testing.T) {
 	assert.Equal(t, ENDED, next)
 	assert.EqualError(t, err, "lazypair : iterable expected got 2")
 }
+
+func Test_END_IterateReturnsError(t *testing.T) {
+	next, err := ENDED.Iterate(GlobalEnvironment)
+	assert.Equal(t, ENDED, next)
+	assert.EqualError(t, err, "END : Iterate called on END")
+}
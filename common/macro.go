@@ -5,10 +5,12 @@ import (
 	"github.com/mikeyhu/glipso/interfaces"
 )
 
-// MAC expands the Expression with Arguments prior to evaluation
+// MAC is a macro, a function from code to code. Expanding it evaluates the Expression with the Arguments bound to the
+// code it was called with, as quoted data, and converts the result back into code to be evaluated in its place.
 type MAC struct {
 	Arguments  VEC
-	Expression *EXP
+	Expression interfaces.Type
+	Scope      interfaces.Scope
 }
 
 // IsType for MAC
@@ -22,50 +24,159 @@ func (m MAC) String() string {
 	return fmt.Sprintf("MAC(%v %v)", m.Arguments, m.Expression)
 }
 
-//Expand will replace references to Arguments with arguments provided and then return it without evaluation
-func (m MAC) Expand(arguments []interfaces.Type) interfaces.Evaluatable {
-	m.printStartExpand()
-	if len(arguments) != len(m.Arguments.Vector) {
-		panic(fmt.Sprintf("Expand : expected %v arguments\n", len(m.Arguments.Vector)))
+// Expand evaluates the Expression with the Arguments bound to arguments, each quoted, and returns the code that results
+// without evaluating it. The Expression is evaluated within the Scope the MAC was created in, or the global
+// Environment of sco if it has none. Variadic Arguments bind their last name to a list of the arguments left over.
+func (m MAC) Expand(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Type, error) {
+	params, variadic, err := Parameters(m.Arguments)
+	if err != nil {
+		return nil, fmt.Errorf("macro : %v", err)
 	}
+	if variadic && len(arguments) < len(params)-1 {
+		return nil, fmt.Errorf("macro : invalid number of arguments [%d of at least %d]", len(arguments), len(params)-1)
+	} else if !variadic && len(arguments) != len(params) {
+		return nil, fmt.Errorf("macro : invalid number of arguments [%d of %d]", len(arguments), len(params))
+	}
+	parent := m.Scope
+	if parent == nil {
+		parent = GlobalOf(sco)
+	}
+	sco = Evaluating(sco)
+	evaluation := EvaluationOf(sco)
+	if err := evaluation.Enter(); err != nil {
+		return nil, err
+	}
+	defer evaluation.Leave(1)
+	macenv := newLexicalScopeUnder(parent, evaluation, len(params))
+	for p, param := range params {
+		var value interfaces.Value
+		if variadic && p == len(params)-1 {
+			rest := make([]interfaces.Value, 0, len(arguments)-p)
+			for _, arg := range arguments[p:] {
+				rest = append(rest, Quote(arg))
+			}
+			value = NewList(rest)
+		} else {
+			value = Quote(arguments[p])
+		}
+		if err := bind(param, value, macenv, macenv); err != nil {
+			return nil, fmt.Errorf("macro : %v", err)
+		}
+	}
+	result, err := Evaluate(m.Expression, macenv)
+	if err != nil {
+		return nil, err
+	}
+	return Code(result, sco)
+}
 
-	variables := make(map[REF]interfaces.Type)
+// MacroExpand1 expands code once if it calls a macro found in sco, returning false if it does not
+func MacroExpand1(code interfaces.Type, sco interfaces.Scope) (interfaces.Type, bool, error) {
+	exp, ok := code.(*EXP)
+	if !ok {
+		return code, false, nil
+	}
+	name, ok := nameOf(exp.Function)
+	if !ok {
+		return code, false, nil
+	}
+	value, _ := sco.ResolveRef(name)
+	mac, ok := value.(interfaces.Expandable)
+	if !ok {
+		return code, false, nil
+	}
+	expanded, err := mac.Expand(exp.Arguments, sco)
+	if err != nil {
+		return nil, false, err
+	}
+	return expanded, true, nil
+}
 
-	for p, ref := range m.Arguments.Vector {
-		variables[ref.(REF)] = arguments[p]
+// MacroExpand expands code repeatedly until it no longer calls a macro. Each expansion counts towards the Depth of the
+// evaluation until the last, so that a macro expanding to a call of itself is bounded.
+func MacroExpand(code interfaces.Type, sco interfaces.Scope) (interfaces.Type, error) {
+	sco = Evaluating(sco)
+	evaluation := EvaluationOf(sco)
+	expansions := 0
+	defer func() { evaluation.Leave(expansions) }()
+	for {
+		expanded, ok, err := MacroExpand1(code, sco)
+		if err != nil || !ok {
+			return code, err
+		}
+		if err := evaluation.Enter(); err != nil {
+			return nil, err
+		}
+		expansions++
+		code = expanded
 	}
+}
 
-	var expandEXP func(*EXP) *EXP
-	expandEXP = func(exp *EXP) *EXP {
-		newArgs := make([]interfaces.Type, len(exp.Arguments))
-		for p, arg := range exp.Arguments {
-			if ref, ok := arg.(REF); ok {
-				if newVal, found := variables[ref]; found {
-					newArgs[p] = newVal
-				} else {
-					newArgs[p] = ref
-				}
-			} else if exp, ok := arg.(*EXP); ok {
-				newArgs[p] = expandEXP(exp)
-			} else {
-				newArgs[p] = arg
-			}
+// MacroExpandAll expands code, and then every form within it, until no macros are called. Quoted code is left as it is.
+// Each form counts towards the Depth of the evaluation while the forms within it are expanded.
+func MacroExpandAll(code interfaces.Type, sco interfaces.Scope) (interfaces.Type, error) {
+	sco = Evaluating(sco)
+	evaluation := EvaluationOf(sco)
+	if err := evaluation.Enter(); err != nil {
+		return nil, err
+	}
+	defer evaluation.Leave(1)
+	expanded, err := MacroExpand(code, sco)
+	if err != nil {
+		return nil, err
+	}
+	switch c := expanded.(type) {
+	case *EXP:
+		if name, _ := nameOf(c.Function); name == "quote" || name == "syntax-quote" {
+			return c, nil
+		}
+		function, err := MacroExpandAll(c.Function, sco)
+		if err != nil {
+			return nil, err
 		}
-		return &EXP{exp.Function, newArgs}
+		arguments, err := macroExpandAll(c.Arguments, sco)
+		if err != nil {
+			return nil, err
+		}
+		return &EXP{Function: function, Arguments: arguments, Pos: c.Pos, Positions: c.Positions}, nil
+	case VEC:
+		vector, err := macroExpandAll(c.Vector, sco)
+		if err != nil {
+			return nil, err
+		}
+		return VEC{Vector: vector, Pos: c.Pos, Positions: c.Positions}, nil
 	}
-	result := expandEXP(m.Expression)
-	m.printEndExpand(result)
-	return result
+	return expanded, nil
 }
 
-func (m MAC) printStartExpand() {
-	if DEBUG {
-		fmt.Printf("Expanding %v\n", m)
+func macroExpandAll(code []interfaces.Type, sco interfaces.Scope) ([]interfaces.Type, error) {
+	expanded := make([]interfaces.Type, len(code))
+	for i, c := range code {
+		var err error
+		if expanded[i], err = MacroExpandAll(c, sco); err != nil {
+			return nil, err
+		}
 	}
+	return expanded, nil
 }
 
-func (m MAC) printEndExpand(expanded interfaces.Type) {
-	if DEBUG {
-		fmt.Printf("Expanded to %v\n", expanded)
+// macroexpander creates a builtin that converts its argument from data to code, expands it with expand and returns
+// the result as data
+func macroexpander(expand func(interfaces.Type, interfaces.Scope) (interfaces.Type, error)) evaluator {
+	return func(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+		code, err := Code(arguments[0], sco)
+		if err != nil {
+			return NILL, err
+		}
+		expanded, err := expand(code, sco)
+		if err != nil {
+			return NILL, err
+		}
+		return Quote(expanded), nil
 	}
 }
+
+func macroexpand1(code interfaces.Type, sco interfaces.Scope) (interfaces.Type, error) {
+	expanded, _, err := MacroExpand1(code, sco)
+	return expanded, err
+}
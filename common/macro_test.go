@@ -6,38 +6,182 @@ import (
 	"testing"
 )
 
+// syntaxQuoted builds `(function args...)
+func syntaxQuoted(function REF, args ...interfaces.Type) *EXP {
+	return EXPBuild(REF("syntax-quote")).withArgs(EXPBuild(function).withArgs(args...).build()).build()
+}
+
+// unquoteOf builds ~ref
+func unquoteOf(ref REF) *EXP {
+	return EXPBuild(REF("unquote")).withArgs(ref).build()
+}
+
 func Test_Macro_Expand(t *testing.T) {
 	macro := MAC{
-		VEC{[]interfaces.Type{REF("a")}},
-		&EXP{REF("+"), []interfaces.Type{REF("a"), I(1)}},
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("+", unquoteOf("a"), I(1)),
 	}
 
-	result := macro.Expand([]interfaces.Type{I(10)})
+	result, err := macro.Expand([]interfaces.Type{I(10)}, GlobalEnvironment)
 
-	assert.Equal(t, &EXP{REF("+"), []interfaces.Type{I(10), I(1)}}, result)
+	assert.NoError(t, err)
+	assert.Equal(t, &EXP{Function: REF("+"), Arguments: []interfaces.Type{I(10), I(1)}}, result)
 }
 
 func Test_Macro_NestedExpansion(t *testing.T) {
 	macro := MAC{
-		VEC{[]interfaces.Type{REF("a")}},
-		&EXP{REF("+"), []interfaces.Type{
-			&EXP{REF("+"), []interfaces.Type{REF("a"), I(1)}}},
-		},
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("+", EXPBuild(REF("+")).withArgs(unquoteOf("a"), I(1)).build()),
+	}
+
+	result, err := macro.Expand([]interfaces.Type{I(10)}, GlobalEnvironment)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &EXP{Function: REF("+"), Arguments: []interfaces.Type{I(10), I(1)}}, result.(*EXP).Arguments[0])
+}
+
+func Test_Macro_ExpandsIntoVEC(t *testing.T) {
+	macro := MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a"), REF("b")}},
+		Expression: syntaxQuoted("let", VEC{Vector: []interfaces.Type{unquoteOf("a"), I(1)}}, unquoteOf("b")),
+	}
+
+	result, err := macro.Expand([]interfaces.Type{REF("x"), EXPBuild(REF("+")).withArgs(REF("x"), I(1)).build()}, GlobalEnvironment)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &EXP{Function: REF("let"), Arguments: []interfaces.Type{
+		VEC{Vector: []interfaces.Type{REF("x"), I(1)}},
+		&EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("x"), I(1)}},
+	}}, result)
+}
+
+func Test_Macro_ErrorsWithWrongNumberOfArguments(t *testing.T) {
+	macro := MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: REF("a"),
+	}
+
+	_, err := macro.Expand([]interfaces.Type{I(1), I(2)}, GlobalEnvironment)
+
+	assert.EqualError(t, err, "macro : invalid number of arguments [2 of 1]")
+}
+
+func Test_Macro_BindsRemainingArgumentsToQuotedList(t *testing.T) {
+	macro := MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("&"), REF("body")}},
+		Expression: syntaxQuoted("do", EXPBuild(REF("unquote-splicing")).withArgs(REF("body")).build()),
 	}
 
-	result := macro.Expand([]interfaces.Type{I(10)})
+	result, err := macro.Expand([]interfaces.Type{REF("a"), EXPBuild(REF("b")).build()}, GlobalEnvironment)
 
-	assert.Equal(t, &EXP{REF("+"), []interfaces.Type{I(10), I(1)}}, result.(*EXP).Arguments[0])
+	assert.NoError(t, err)
+	assert.Equal(t, &EXP{Function: REF("do"), Arguments: []interfaces.Type{REF("a"), &EXP{Function: REF("b"), Arguments: []interfaces.Type{}}}}, result)
 }
 
 func Test_Macro_FoundAndExpanded(t *testing.T) {
 	GlobalEnvironment.CreateRef(REF("adder"), MAC{
-		VEC{[]interfaces.Type{REF("a")}},
-		&EXP{REF("+"), []interfaces.Type{REF("a"), I(1)}},
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("+", unquoteOf("a"), I(1)),
 	})
 
-	expression := &EXP{REF("adder"), []interfaces.Type{I(10)}}
+	expression := &EXP{Function: REF("adder"), Arguments: []interfaces.Type{I(10)}}
 
 	result, _ := expression.Evaluate(GlobalEnvironment)
 	assert.Equal(t, I(11), result)
 }
+
+func Test_Macro_AutoGensymsAreUniqueToEachExpansion(t *testing.T) {
+	macro := MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("let", VEC{Vector: []interfaces.Type{REF("x#"), unquoteOf("a")}}, REF("x#")),
+	}
+
+	first, err := macro.Expand([]interfaces.Type{REF("x")}, GlobalEnvironment)
+	assert.NoError(t, err)
+	second, err := macro.Expand([]interfaces.Type{REF("x")}, GlobalEnvironment)
+	assert.NoError(t, err)
+
+	binding := first.(*EXP).Arguments[0].(VEC).Vector[0]
+	assert.Equal(t, binding, first.(*EXP).Arguments[1])
+	assert.Equal(t, REF("x"), first.(*EXP).Arguments[0].(VEC).Vector[1])
+	assert.Regexp(t, `^x__\d+__auto__$`, binding.String())
+	assert.NotEqual(t, binding, second.(*EXP).Arguments[0].(VEC).Vector[0])
+}
+
+func Test_MacroExpand1_ExpandsOnce(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.CreateRef(REF("twice"), MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("double", unquoteOf("a"), unquoteOf("a")),
+	})
+	env.CreateRef(REF("double"), MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a"), REF("b")}},
+		Expression: syntaxQuoted("+", unquoteOf("a"), unquoteOf("b")),
+	})
+	code := EXPBuild(REF("twice")).withArgs(I(1)).build()
+
+	//when
+	once, expanded, err := MacroExpand1(code, env)
+	assert.NoError(t, err)
+	fully, fullErr := MacroExpand(code, env)
+
+	//then
+	assert.True(t, expanded)
+	assert.Equal(t, &EXP{Function: REF("double"), Arguments: []interfaces.Type{I(1), I(1)}}, once)
+	assert.NoError(t, fullErr)
+	assert.Equal(t, &EXP{Function: REF("+"), Arguments: []interfaces.Type{I(1), I(1)}}, fully)
+}
+
+func Test_MacroExpand1_LeavesOtherCodeAsItIs(t *testing.T) {
+	//given
+	code := EXPBuild(REF("+")).withArgs(I(1)).build()
+
+	//when
+	result, expanded, err := MacroExpand1(code, GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.False(t, expanded)
+	assert.Equal(t, code, result)
+}
+
+func Test_MacroExpandAll_ExpandsNestedFormsButNotQuotedCode(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.CreateRef(REF("inc"), MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("+", unquoteOf("a"), I(1)),
+	})
+	inc := EXPBuild(REF("inc")).withArgs(REF("x")).build()
+	quoted := EXPBuild(REF("quote")).withArgs(inc).build()
+	code := EXPBuild(REF("let")).withArgs(VEC{Vector: []interfaces.Type{REF("x"), inc}}, quoted).build()
+
+	//when
+	result, err := MacroExpandAll(code, env)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, &EXP{Function: REF("let"), Arguments: []interfaces.Type{
+		VEC{Vector: []interfaces.Type{REF("x"), &EXP{Function: REF("+"), Arguments: []interfaces.Type{REF("x"), I(1)}}}},
+		quoted,
+	}}, result)
+}
+
+func Test_macroexpand_ReturnsData(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.CreateRef(REF("inc"), MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("+", unquoteOf("a"), I(1)),
+	})
+	code := EXPBuild(REF("inc")).withArgs(REF("x")).build()
+	exp := EXPBuild(REF("macroexpand")).withArgs(EXPBuild(REF("quote")).withArgs(code).build()).build()
+
+	//when
+	result, err := Evaluate(exp, env)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, "(+ x 1)", Sprint(result, env))
+}
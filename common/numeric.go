@@ -1,11 +1,12 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mikeyhu/glipso/interfaces"
 )
 
-type numericCombiner func(interfaces.Numeric, interfaces.Numeric) interfaces.Numeric
+type numericCombiner func(interfaces.Numeric, interfaces.Numeric) (interfaces.Numeric, error)
 
 func numericFlatten(args []interfaces.Value, combiner numericCombiner) (interfaces.Value, error) {
 	var all interfaces.Numeric
@@ -19,9 +20,15 @@ func numericFlatten(args []interfaces.Value, combiner numericCombiner) (interfac
 			all = vAsN
 			head = false
 		} else {
-			all = combiner(all, vAsN)
+			var err error
+			if all, err = combiner(all, vAsN); err != nil {
+				return NILL, err
+			}
 		}
 	}
+	if head {
+		return NILL, errors.New("numericFlatten : expected at least one argument")
+	}
 	return all, nil
 }
 
@@ -82,55 +89,61 @@ func (i I) CompareTo(o interfaces.Comparable) (int, error) {
 }
 
 // Add for I
-func (i I) Add(n interfaces.Numeric) interfaces.Numeric {
+func (i I) Add(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(I); ok {
-		return i + other
+		return i + other, nil
 	}
 	if other, ok := n.(F); ok {
-		return i.asF() + other
+		return i.asF() + other, nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Add : cannot add %v to %v", n, i)
 }
 
 // Subtract for I
-func (i I) Subtract(n interfaces.Numeric) interfaces.Numeric {
+func (i I) Subtract(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(I); ok {
-		return i - other
+		return i - other, nil
 	}
 	if other, ok := n.(F); ok {
-		return i.asF() - other
+		return i.asF() - other, nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Subtract : cannot subtract %v from %v", n, i)
 }
 
 // Multiply for I
-func (i I) Multiply(n interfaces.Numeric) interfaces.Numeric {
+func (i I) Multiply(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(I); ok {
-		return i * other
+		return i * other, nil
 	}
 	if other, ok := n.(F); ok {
-		return i.asF() * other
+		return i.asF() * other, nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Multiply : cannot multiply %v by %v", i, n)
 }
 
-// Divide for I
-func (i I) Divide(n interfaces.Numeric) interfaces.Numeric {
+// Divide for I, failing should n be zero
+func (i I) Divide(n interfaces.Numeric) (interfaces.Numeric, error) {
+	if n == I(0) {
+		return nil, fmt.Errorf("Divide : cannot divide %v by zero", i)
+	}
 	if other, ok := n.(I); ok {
-		return i / other
+		return i / other, nil
 	}
 	if other, ok := n.(F); ok {
-		return i.asF() / other
+		return i.asF() / other, nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Divide : cannot divide %v by %v", i, n)
 }
 
-// Mod for I
-func (i I) Mod(n interfaces.Numeric) interfaces.Numeric {
+// Mod for I, failing should n be zero
+func (i I) Mod(n interfaces.Numeric) (interfaces.Numeric, error) {
+	if n == I(0) {
+		return nil, fmt.Errorf("Mod : cannot find %v modulo zero", i)
+	}
 	if other, ok := n.(I); ok {
-		return i % other
+		return i % other, nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Mod : cannot find %v modulo %v", i, n)
 }
 
 // F (Float)
@@ -180,45 +193,45 @@ func (f F) CompareTo(o interfaces.Comparable) (int, error) {
 }
 
 // Add for F
-func (f F) Add(n interfaces.Numeric) interfaces.Numeric {
+func (f F) Add(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(F); ok {
-		return f + other
+		return f + other, nil
 	}
 	if other, ok := n.(I); ok {
-		return f + other.asF()
+		return f + other.asF(), nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Add : cannot add %v to %v", n, f)
 }
 
 // Subtract for F
-func (f F) Subtract(n interfaces.Numeric) interfaces.Numeric {
+func (f F) Subtract(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(F); ok {
-		return f - other
+		return f - other, nil
 	}
 	if other, ok := n.(I); ok {
-		return f - other.asF()
+		return f - other.asF(), nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Subtract : cannot subtract %v from %v", n, f)
 }
 
 // Multiply for F
-func (f F) Multiply(n interfaces.Numeric) interfaces.Numeric {
+func (f F) Multiply(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(F); ok {
-		return f * other
+		return f * other, nil
 	}
 	if other, ok := n.(I); ok {
-		return f * other.asF()
+		return f * other.asF(), nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Multiply : cannot multiply %v by %v", f, n)
 }
 
 // Divide for F
-func (f F) Divide(n interfaces.Numeric) interfaces.Numeric {
+func (f F) Divide(n interfaces.Numeric) (interfaces.Numeric, error) {
 	if other, ok := n.(F); ok {
-		return f / other
+		return f / other, nil
 	}
 	if other, ok := n.(I); ok {
-		return f / other.asF()
+		return f / other.asF(), nil
 	}
-	panic("not implemented")
+	return nil, fmt.Errorf("Divide : cannot divide %v by %v", f, n)
 }
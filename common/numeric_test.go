@@ -75,9 +75,10 @@ func Test_I_Add_I(t *testing.T) {
 	b := I(200)
 
 	//when
-	result := a.Add(b)
+	result, err := a.Add(b)
 
 	//then
+	assert.NoError(t, err)
 	assert.Equal(t, I(300), result)
 }
 
@@ -87,9 +88,10 @@ func Test_I_Subtract_I(t *testing.T) {
 	b := I(50)
 
 	//when
-	result := a.Subtract(b)
+	result, err := a.Subtract(b)
 
 	//then
+	assert.NoError(t, err)
 	assert.Equal(t, I(50), result)
 }
 
@@ -99,9 +101,10 @@ func Test_I_Multiply_I(t *testing.T) {
 	b := I(3)
 
 	//when
-	result := a.Multiply(b)
+	result, err := a.Multiply(b)
 
 	//then
+	assert.NoError(t, err)
 	assert.Equal(t, I(6), result)
 }
 
@@ -111,9 +114,10 @@ func Test_I_Divide_I(t *testing.T) {
 	b := I(2)
 
 	//when
-	result := a.Divide(b)
+	result, err := a.Divide(b)
 
 	//then
+	assert.NoError(t, err)
 	assert.Equal(t, I(50), result)
 }
 
@@ -123,12 +127,25 @@ func Test_I_Mod_I(t *testing.T) {
 	b := I(7)
 
 	//when
-	result := a.Mod(b)
+	result, err := a.Mod(b)
 
 	//then
+	assert.NoError(t, err)
 	assert.Equal(t, I(1), result)
 }
 
+func Test_I_Divide_Zero(t *testing.T) {
+	//given
+	a := I(100)
+	b := I(0)
+
+	//when
+	_, err := a.Divide(b)
+
+	//then
+	assert.EqualError(t, err, "Divide : cannot divide 100 by zero")
+}
+
 // F Float
 
 func Test_F_Equals_F(t *testing.T) {
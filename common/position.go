@@ -0,0 +1,104 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Source holds the name and text of some parsed code so that positions can refer back to it
+type Source struct {
+	Name  string
+	lines []string
+}
+
+// NewSource creates a Source from the name and contents of some code
+func NewSource(name string, text string) *Source {
+	return &Source{Name: name, lines: strings.Split(text, "\n")}
+}
+
+// Line returns the text of a line within the Source, counting from 1
+func (s *Source) Line(line int) string {
+	if s == nil || line < 1 || line > len(s.lines) {
+		return ""
+	}
+	return strings.TrimRight(s.lines[line-1], "\r")
+}
+
+// Pos is a location within a Source, Line and Column both count from 1
+type Pos struct {
+	Source *Source
+	Line   int
+	Column int
+}
+
+// IsValid returns true if the Pos refers to a location
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String representation of Pos as file:line:column
+func (p Pos) String() string {
+	if p.Source == nil || p.Source.Name == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Source.Name, p.Line, p.Column)
+}
+
+// Excerpt returns the line of source containing the Pos with a caret underneath pointing at the column
+func (p Pos) Excerpt() string {
+	line := p.Source.Line(p.Line)
+	if line == "" {
+		return ""
+	}
+	caret := strings.Builder{}
+	for i, r := range []rune(line) {
+		if i >= p.Column-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return line + "\n" + caret.String()
+}
+
+// SourceError is an error that can be traced back to a position in the source
+type SourceError struct {
+	Pos Pos
+	Err error
+}
+
+// Error returns the position and message of the SourceError
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Pos, e.Err)
+}
+
+// Unwrap returns the error that occurred at the position
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorDetail returns the message of an error along with an excerpt of the source that caused it, if known,
+// and the stack of function applications for an EvalError
+func ErrorDetail(err error) string {
+	detail := err.Error()
+	var pos Pos
+	var evalErr *EvalError
+	var srcErr *SourceError
+	if errors.As(err, &evalErr) {
+		pos = evalErr.Pos
+	} else if errors.As(err, &srcErr) {
+		pos = srcErr.Pos
+	}
+	if excerpt := pos.Excerpt(); excerpt != "" {
+		detail += "\n" + excerpt
+	}
+	if evalErr != nil && len(evalErr.Frames) > 0 {
+		detail += "\n" + evalErr.StackTrace()
+	}
+	return detail
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"errors"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Pos_StringIncludesSourceName(t *testing.T) {
+	pos := Pos{NewSource("file.glipso", "(+ 1 2)"), 12, 5}
+	assert.Equal(t, "file.glipso:12:5", pos.String())
+}
+
+func Test_Pos_StringWithoutSourceName(t *testing.T) {
+	pos := Pos{NewSource("", "(+ 1 2)"), 1, 3}
+	assert.Equal(t, "1:3", pos.String())
+}
+
+func Test_Pos_ExcerptPointsAtColumn(t *testing.T) {
+	pos := Pos{NewSource("file.glipso", "(do\n\t(+ 1 x))"), 2, 7}
+	assert.Equal(t, "\t(+ 1 x))\n\t     ^", pos.Excerpt())
+}
+
+func Test_ErrorDetail_IncludesExcerpt(t *testing.T) {
+	err := &SourceError{Pos{NewSource("file.glipso", "(oops)"), 1, 2}, errors.New("not found")}
+	assert.Equal(t, "file.glipso:1:2: not found\n(oops)\n ^", ErrorDetail(err))
+}
+
+func Test_Evaluate_UnresolvedREFReportsItsPosition(t *testing.T) {
+	source := NewSource("file.glipso", "(+ 1 x)")
+	exp := &EXP{
+		Function:  REF("+"),
+		Arguments: []interfaces.Type{I(1), REF("x")},
+		Pos:       Pos{source, 1, 1},
+		Positions: []Pos{{source, 1, 2}, {source, 1, 4}, {source, 1, 6}},
+	}
+	_, err := exp.Evaluate(GlobalEnvironment)
+	assert.EqualError(t, err, "file.glipso:1:6: unable to resolve REF('x')")
+}
+
+func Test_Evaluate_ErrorReportsInnermostPosition(t *testing.T) {
+	source := NewSource("file.glipso", "(do (% 1))")
+	exp := &EXP{
+		Function: REF("do"),
+		Arguments: []interfaces.Type{&EXP{
+			Function:  REF("%"),
+			Arguments: []interfaces.Type{I(1)},
+			Pos:       Pos{source, 1, 5},
+		}},
+		Pos: Pos{source, 1, 1},
+	}
+	_, err := exp.Evaluate(GlobalEnvironment)
+	assert.EqualError(t, err, "file.glipso:1:5: % : invalid number of arguments [1 of 2]")
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// printLimit is the number of items of a list that Sprint shows before eliding the rest, so that infinite lazy lists
+// can be printed
+const printLimit = 100
+
+// Sprint returns a readable representation of value, in the syntax it would be written in where there is one, so that
+// code is printed as it would be written too. Lazy lists are iterated within sco.
+func Sprint(value interfaces.Type, sco interfaces.Scope) string {
+	var b strings.Builder
+	sprint(&b, value, sco)
+	return b.String()
+}
+
+func sprint(b *strings.Builder, value interfaces.Type, sco interfaces.Scope) {
+	switch v := value.(type) {
+	case NIL:
+		b.WriteString("nil")
+	case S:
+		b.WriteString(strconv.Quote(string(v)))
+	case F:
+		f := strconv.FormatFloat(float64(v), 'g', -1, 64)
+		if !strings.ContainsAny(f, ".eIN") {
+			f += ".0"
+		}
+		b.WriteString(f)
+	case *EXP:
+		sprint(b, Quote(v), sco)
+	case VEC:
+		b.WriteString("[")
+		for i, item := range v.Vector {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			sprint(b, item, sco)
+		}
+		b.WriteString("]")
+	case *MAP:
+		entries := v.entries()
+		items := make([]string, 0, len(entries))
+		for k, item := range entries {
+			items = append(items, Sprint(k.(interfaces.Value), sco)+" "+Sprint(item, sco))
+		}
+		sort.Strings(items)
+		b.WriteString("{" + strings.Join(items, ", ") + "}")
+	case interfaces.Iterable:
+		b.WriteString("(")
+		for n, next := 0, v; next != ENDED; n++ {
+			if n == printLimit {
+				b.WriteString(" ...")
+				break
+			}
+			if n > 0 {
+				b.WriteString(" ")
+			}
+			sprint(b, next.Head(), sco)
+			if !next.HasTail() {
+				break
+			}
+			var err error
+			if next, err = next.Iterate(sco); err != nil {
+				b.WriteString(" ...")
+				break
+			}
+		}
+		b.WriteString(")")
+	default:
+		fmt.Fprintf(b, "%v", value)
+	}
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Sprint_Values(t *testing.T) {
+	//given
+	m, _ := initialiseMAP([]interfaces.Value{SYM(":b"), S("two"), SYM(":a"), I(1)})
+	vec := VEC{Vector: []interfaces.Type{I(1), F(2.5), F(3), B(true), NILL}}
+
+	//then
+	assert.Equal(t, `{:a 1, :b "two"}`, Sprint(m, GlobalEnvironment))
+	assert.Equal(t, "[1 2.5 3.0 true nil]", Sprint(vec, GlobalEnvironment))
+	assert.Equal(t, "(1 (2))", Sprint(P{I(1), P{P{I(2), ENDED}, ENDED}}, GlobalEnvironment))
+	assert.Equal(t, "()", Sprint(ENDED, GlobalEnvironment))
+}
+
+func Test_Sprint_ElidesLongLists(t *testing.T) {
+	//given
+	list, _ := EXPBuild(REF("range")).withArgs(I(1), I(1000)).build().Evaluate(GlobalEnvironment)
+
+	//when
+	result := Sprint(list, GlobalEnvironment)
+
+	//then
+	assert.True(t, len(result) < 400)
+	assert.Contains(t, result, "(1 2 3 ")
+	assert.Contains(t, result, " 100 ...)")
+}
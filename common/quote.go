@@ -0,0 +1,220 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"strings"
+	"sync/atomic"
+)
+
+// QREF (Quoted Reference)
+// a REF that has been quoted so that it is data, rather than a reference to something in scope
+type QREF string
+
+// IsType for QREF
+func (q QREF) IsType() {}
+
+// IsValue for QREF
+func (q QREF) IsValue() {}
+
+// String representation of QREF
+func (q QREF) String() string {
+	return string(q)
+}
+
+// Equals checks equality with another item of type Equalable
+func (q QREF) Equals(o interfaces.Equalable) interfaces.Value {
+	if other, ok := o.(QREF); ok {
+		return B(q == other)
+	}
+	return B(false)
+}
+
+// Quote converts code into data: each EXP becomes a list of its Function followed by its Arguments, each REF becomes
+// a QREF and the items of each VEC are quoted in turn
+func Quote(code interfaces.Type) interfaces.Value {
+	switch c := code.(type) {
+	case *EXP:
+		items := make([]interfaces.Value, 0, len(c.Arguments)+1)
+		items = append(items, Quote(c.Function))
+		for _, arg := range c.Arguments {
+			items = append(items, Quote(arg))
+		}
+		return NewList(items)
+	case VEC:
+		vector := make([]interfaces.Type, len(c.Vector))
+		for i, item := range c.Vector {
+			vector[i] = Quote(item)
+		}
+		return VEC{Vector: vector, Pos: c.Pos, Positions: c.Positions}
+	case interfaces.Value:
+		return c
+	}
+	if name, ok := nameOf(code); ok {
+		return QREF(name)
+	}
+	return NILL
+}
+
+// Code converts data back into code, reversing Quote: each list becomes an EXP with its first item as the Function and
+// each QREF becomes a REF. Lazy lists are iterated within sco.
+func Code(data interfaces.Type, sco interfaces.Scope) (interfaces.Type, error) {
+	switch d := data.(type) {
+	case QREF:
+		return REF(d), nil
+	case VEC:
+		vector := make([]interfaces.Type, len(d.Vector))
+		for i, item := range d.Vector {
+			code, err := Code(item, sco)
+			if err != nil {
+				return nil, err
+			}
+			vector[i] = code
+		}
+		return VEC{Vector: vector, Pos: d.Pos, Positions: d.Positions}, nil
+	case interfaces.Iterable:
+		if d == ENDED {
+			return nil, fmt.Errorf("code : unable to convert an empty list to an EXP")
+		}
+		items, _, err := itemsOf(d, sco, DefaultLimit)
+		if err != nil {
+			return nil, err
+		}
+		code := make([]interfaces.Type, len(items))
+		for i, item := range items {
+			if code[i], err = Code(item, sco); err != nil {
+				return nil, err
+			}
+		}
+		return &EXP{Function: code[0], Arguments: code[1:]}, nil
+	}
+	return data, nil
+}
+
+func quote(arguments []interfaces.Type, _ interfaces.Scope) (interfaces.Value, error) {
+	return Quote(arguments[0]), nil
+}
+
+// syntaxQuote quotes a template as quote does, except that forms within unquote are evaluated and the items of lists
+// within unquote-splicing are spliced into the list or VEC that contains them. Each REF ending in # is replaced by a
+// symbol from gensym, the same one wherever it appears within the template.
+func syntaxQuote(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	t := &template{sco: sco, gensyms: map[REF]QREF{}}
+	return t.quote(arguments[0])
+}
+
+// template holds the state of a syntax-quote while its template is quoted
+type template struct {
+	sco     interfaces.Scope
+	gensyms map[REF]QREF
+}
+
+func (t *template) quote(code interfaces.Type) (interfaces.Value, error) {
+	switch c := code.(type) {
+	case *EXP:
+		if form, ok := unquoted(c, "unquote"); ok {
+			return evaluateToValue(form, t.sco)
+		}
+		if _, ok := unquoted(c, "unquote-splicing"); ok {
+			return NILL, fmt.Errorf("syntax-quote : unquote-splicing %v is not within a list or VEC", c.Arguments[0])
+		}
+		items, err := t.quoteItems(append([]interfaces.Type{c.Function}, c.Arguments...))
+		if err != nil {
+			return NILL, err
+		}
+		return NewList(items), nil
+	case VEC:
+		items, err := t.quoteItems(c.Vector)
+		if err != nil {
+			return NILL, err
+		}
+		vector := make([]interfaces.Type, len(items))
+		for i, item := range items {
+			vector[i] = item
+		}
+		return VEC{Vector: vector}, nil
+	}
+	if name, ok := nameOf(code); ok && len(name) > 1 && strings.HasSuffix(string(name), "#") {
+		if _, ok := t.gensyms[name]; !ok {
+			t.gensyms[name] = QREF(fmt.Sprintf("%v__%d__auto__", strings.TrimSuffix(string(name), "#"), nextGensym()))
+		}
+		return t.gensyms[name], nil
+	}
+	return Quote(code), nil
+}
+
+func (t *template) quoteItems(code []interfaces.Type) ([]interfaces.Value, error) {
+	items := make([]interfaces.Value, 0, len(code))
+	for _, item := range code {
+		if exp, ok := item.(*EXP); ok {
+			if form, ok := unquoted(exp, "unquote-splicing"); ok {
+				value, err := evaluateToValue(form, t.sco)
+				if err != nil {
+					return nil, err
+				}
+				spliced, ok, err := itemsOf(value, t.sco, DefaultLimit)
+				if !ok {
+					return nil, fmt.Errorf("syntax-quote : expected a list or VEC to splice, recieved %v", value)
+				} else if err != nil {
+					return nil, err
+				}
+				items = append(items, spliced...)
+				continue
+			}
+		}
+		quoted, err := t.quote(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, quoted)
+	}
+	return items, nil
+}
+
+// unquoted returns the form within an EXP such as (unquote form), when the EXP is of that kind
+func unquoted(exp *EXP, kind REF) (interfaces.Type, bool) {
+	if name, ok := nameOf(exp.Function); ok && name == kind && len(exp.Arguments) == 1 {
+		return exp.Arguments[0], true
+	}
+	return nil, false
+}
+
+// gensymCount makes each symbol returned by gensym unique
+var gensymCount atomic.Int64
+
+func nextGensym() int64 {
+	return gensymCount.Add(1)
+}
+
+// gensym returns a symbol that is unique, so that code generated by a macro can bind it without capturing any
+// variable of the code the macro is used in. The symbol begins with the prefix provided, or G__ if there is none.
+func gensym(arguments []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+	prefix := "G__"
+	if len(arguments) > 1 {
+		return NILL, fmt.Errorf("gensym : invalid number of arguments [%d of at most 1]", len(arguments))
+	} else if len(arguments) == 1 {
+		s, ok := arguments[0].(S)
+		if !ok {
+			return NILL, fmt.Errorf("gensym : expected S, recieved %v", arguments[0])
+		}
+		prefix = string(s)
+	}
+	return QREF(fmt.Sprintf("%v%d", prefix, nextGensym())), nil
+}
+
+func unquote(arguments []interfaces.Type, _ interfaces.Scope) (interfaces.Value, error) {
+	return NILL, fmt.Errorf("unquote : %v is not within a syntax-quote", arguments[0])
+}
+
+func unquoteSplicing(arguments []interfaces.Type, _ interfaces.Scope) (interfaces.Value, error) {
+	return NILL, fmt.Errorf("unquote-splicing : %v is not within a syntax-quote", arguments[0])
+}
+
+// eval converts data back into code and evaluates it
+func eval(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+	code, err := Code(arguments[0], sco)
+	if err != nil {
+		return NILL, err
+	}
+	return Evaluate(code, sco)
+}
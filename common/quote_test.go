@@ -0,0 +1,112 @@
+package common
+
+import (
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Quote_ConvertsEXPToList(t *testing.T) {
+	//given
+	code := EXPBuild(REF("+")).withArgs(I(1), EXPBuild(REF("*")).withArgs(REF("x"), I(2)).build()).build()
+	//when
+	result := Quote(code)
+	//then
+	assert.Equal(t, P{QREF("+"), P{I(1), P{P{QREF("*"), P{QREF("x"), P{I(2), ENDED}}}, ENDED}}}, result)
+}
+
+func Test_Quote_QuotesItemsOfVEC(t *testing.T) {
+	//when
+	result := Quote(VEC{Vector: []interfaces.Type{REF("a"), LREF{Name: "b"}, I(1)}})
+	//then
+	assert.Equal(t, VEC{Vector: []interfaces.Type{QREF("a"), QREF("b"), I(1)}}, result)
+}
+
+func Test_Code_ConvertsListToEXP(t *testing.T) {
+	//given
+	data := P{QREF("+"), P{I(1), P{VEC{Vector: []interfaces.Type{QREF("a")}}, ENDED}}}
+	//when
+	result, err := Code(data, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, &EXP{Function: REF("+"), Arguments: []interfaces.Type{I(1), VEC{Vector: []interfaces.Type{REF("a")}}}}, result)
+}
+
+func Test_Code_ErrorsForEmptyList(t *testing.T) {
+	//when
+	_, err := Code(ENDED, GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "code : unable to convert an empty list to an EXP")
+}
+
+func Test_quote_ReturnsDataUnevaluated(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("quote")).withArgs(EXPBuild(REF("undefined")).withArgs(REF("x")).build()).build()
+	//when
+	result, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{QREF("undefined"), P{QREF("x"), ENDED}}, result)
+}
+
+func Test_syntaxQuote_UnquotesAndSplices(t *testing.T) {
+	//given
+	env := NewEnvironment()
+	env.CreateRef(REF("x"), I(5))
+	env.CreateRef(REF("ys"), P{I(1), P{I(2), ENDED}})
+	template := EXPBuild(REF("+")).withArgs(
+		EXPBuild(REF("unquote")).withArgs(REF("x")).build(),
+		EXPBuild(REF("unquote-splicing")).withArgs(REF("ys")).build(),
+		REF("z"),
+	).build()
+	exp := EXPBuild(REF("syntax-quote")).withArgs(template).build()
+	//when
+	result, err := Evaluate(exp, env)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, P{QREF("+"), P{I(5), P{I(1), P{I(2), P{QREF("z"), ENDED}}}}}, result)
+}
+
+func Test_syntaxQuote_ErrorsWhenSplicingNonList(t *testing.T) {
+	//given
+	template := EXPBuild(REF("a")).withArgs(EXPBuild(REF("unquote-splicing")).withArgs(I(1)).build()).build()
+	exp := EXPBuild(REF("syntax-quote")).withArgs(template).build()
+	//when
+	_, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "syntax-quote : expected a list or VEC to splice, recieved 1")
+}
+
+func Test_unquote_ErrorsOutsideSyntaxQuote(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("unquote")).withArgs(REF("x")).build()
+	//when
+	_, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.EqualError(t, err, "unquote : x is not within a syntax-quote")
+}
+
+func Test_eval_EvaluatesData(t *testing.T) {
+	//given
+	data := EXPBuild(REF("quote")).withArgs(EXPBuild(REF("+")).withArgs(I(1), I(2)).build()).build()
+	exp := EXPBuild(REF("eval")).withArgs(data).build()
+	//when
+	result, err := Evaluate(exp, GlobalEnvironment)
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(3), result)
+}
+
+func Test_gensym_ReturnsUniqueSymbolsWithPrefix(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("gensym")).withArgs(S("x")).build()
+	//when
+	first, err := exp.Evaluate(GlobalEnvironment)
+	assert.NoError(t, err)
+	second, err := exp.Evaluate(GlobalEnvironment)
+	assert.NoError(t, err)
+	//then
+	assert.IsType(t, QREF(""), first)
+	assert.Regexp(t, `^x\d+$`, first.String())
+	assert.NotEqual(t, first, second)
+}
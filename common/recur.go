@@ -0,0 +1,108 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+)
+
+// recurValue is returned by recur, holding the values to rebind before the enclosing loop is evaluated again
+type recurValue struct {
+	values []interfaces.Value
+}
+
+// IsType for recurValue
+func (r *recurValue) IsType() {}
+
+// IsValue for recurValue
+func (r *recurValue) IsValue() {}
+
+// String representation of recurValue
+func (r *recurValue) String() string {
+	return fmt.Sprintf("RECUR%v", r.values)
+}
+
+var errRecurNotInTailPosition = errors.New("recur : can only be used in tail position of loop")
+
+// checkRecur walks code ensuring that recur only appears in tail position with the number of arguments that the
+// enclosing loop binds. An arity of -1 means there is no enclosing loop. Bodies of nested loops and fns, including
+// those defined by letfn, are not walked, as they are checked when they are evaluated or created. Calls to macros found
+// in sco are expanded and their expansion walked instead, leaving any error expanding them to be reported when evaluated.
+func checkRecur(code interfaces.Type, arity int, tail bool, sco interfaces.Scope) error {
+	switch c := code.(type) {
+	case *EXP:
+		name, _ := nameOf(c.Function)
+		switch name {
+		case "recur":
+			if arity < 0 {
+				return errors.New("recur : can only be used within loop")
+			}
+			if !tail {
+				return errRecurNotInTailPosition
+			}
+			if len(c.Arguments) != arity {
+				return fmt.Errorf("recur : expected %d arguments, recieved %d", arity, len(c.Arguments))
+			}
+			return checkRecurAll(c.Arguments, arity, sco)
+		case "if":
+			for p, arg := range c.Arguments {
+				if err := checkRecur(arg, arity, tail && p > 0, sco); err != nil {
+					return err
+				}
+			}
+			return nil
+		case "do":
+			for p, arg := range c.Arguments {
+				if err := checkRecur(arg, arity, tail && p == len(c.Arguments)-1, sco); err != nil {
+					return err
+				}
+			}
+			return nil
+		case "let":
+			for p, arg := range c.Arguments {
+				if err := checkRecur(arg, arity, tail && p == 1, sco); err != nil {
+					return err
+				}
+			}
+			return nil
+		case "letfn":
+			if len(c.Arguments) > 1 {
+				return checkRecur(c.Arguments[1], arity, tail, sco)
+			}
+			return nil
+		case "loop":
+			if len(c.Arguments) > 0 {
+				return checkRecur(c.Arguments[0], arity, false, sco)
+			}
+			return nil
+		case "fn", "macro", "quote", "syntax-quote":
+			return nil
+		}
+		if _, local := c.Function.(LREF); !local {
+			if expanded, ok, err := MacroExpand1(c, sco); ok && err == nil {
+				evaluation := EvaluationOf(sco)
+				if err := evaluation.Enter(); err != nil {
+					return err
+				}
+				defer evaluation.Leave(1)
+				return checkRecur(expanded, arity, tail, sco)
+			}
+		}
+		if err := checkRecur(c.Function, arity, false, sco); err != nil {
+			return err
+		}
+		return checkRecurAll(c.Arguments, arity, sco)
+	case VEC:
+		return checkRecurAll(c.Vector, arity, sco)
+	}
+	return nil
+}
+
+func checkRecurAll(code []interfaces.Type, arity int, sco interfaces.Scope) error {
+	for _, c := range code {
+		if err := checkRecur(c, arity, false, sco); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+	"sync/atomic"
+)
+
+// LREF is a REF resolved to the lexical address of the fn, let or loop binding it refers to, Depth scopes up from
+// where it is evaluated and at Index within that scope
+type LREF struct {
+	Name  REF
+	Depth int
+	Index int
+}
+
+// IsType for LREF
+func (l LREF) IsType() {}
+
+// String representation of LREF
+func (l LREF) String() string {
+	return string(l.Name)
+}
+
+// Evaluate finds the value at the lexical address of the LREF
+func (l LREF) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
+	return evaluateReference(l, sco)
+}
+
+// resolve walks up Depth scopes to find the value at Index. Should the scopes not be the ones that were expected when
+// the LREF was resolved, such as within the expansion of a macro, it falls back to looking up the name.
+func (l LREF) resolve(sco interfaces.Scope) (interfaces.Value, bool) {
+	s := sco
+	for d := 0; d < l.Depth; d++ {
+		lexical, ok := s.(*lexicalScope)
+		if !ok {
+			return sco.ResolveRef(l.Name)
+		}
+		s = lexical.parent
+	}
+	if lexical, ok := s.(*lexicalScope); ok && l.Index < len(lexical.names) && lexical.names[l.Index] == l.Name {
+		return lexical.values[l.Index], true
+	}
+	return sco.ResolveRef(l.Name)
+}
+
+// GREF is a REF that is not bound by any enclosing fn, let or loop, and so refers to a variable of the global
+// Environment it was resolved against. It caches the value it refers to until a variable is next created there.
+type GREF struct {
+	Name   REF
+	env    *Environment
+	cached atomic.Pointer[cachedValue]
+}
+
+// cachedValue is the value of a GREF along with the version of the variables it was found in
+type cachedValue struct {
+	value   interfaces.Value
+	version int64
+}
+
+// IsType for GREF
+func (g *GREF) IsType() {}
+
+// String representation of GREF
+func (g *GREF) String() string {
+	return string(g.Name)
+}
+
+// Evaluate finds the value of the GREF in its Environment
+func (g *GREF) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
+	return evaluateReference(g, sco)
+}
+
+func (g *GREF) resolve(_ interfaces.Scope) (interfaces.Value, bool) {
+	return g.env.resolveGlobal(g)
+}
+
+// reference is implemented by REF, LREF and GREF which each name something in scope
+type reference interface {
+	interfaces.Type
+	resolve(interfaces.Scope) (interfaces.Value, bool)
+}
+
+func evaluateReference(ref reference, sco interfaces.Scope) (interfaces.Value, error) {
+	if resolved, ok := ref.resolve(sco); ok {
+		return resolved, nil
+	}
+	name, _ := nameOf(ref)
+	return NILL, &unresolvedError{name, fmt.Sprintf("unable to resolve REF('%v')", name)}
+}
+
+// nameOf returns the REF that a REF, LREF or GREF was resolved from
+func nameOf(code interfaces.Type) (REF, bool) {
+	switch ref := code.(type) {
+	case REF:
+		return ref, true
+	case LREF:
+		return ref.Name, true
+	case *GREF:
+		return ref.Name, true
+	}
+	return "", false
+}
+
+// Resolve returns a copy of code in which each REF bound by an enclosing fn, let or loop is replaced by an LREF
+// holding its lexical address. When sco is a global Environment, or is within a fn, let or loop evaluated in one, any
+// other REF is replaced by a GREF so that its value can be cached.
+func Resolve(code interfaces.Type, sco interfaces.Scope) interfaces.Type {
+	r, scope := newResolver(sco)
+	return r.resolve(code, scope)
+}
+
+// lexical is the compile time counterpart of a lexicalScope, holding the names it will bind. A lexical is opaque once
+// it holds a call to a macro, which may def names that cannot be known without running the macro.
+type lexical struct {
+	names  []REF
+	parent *lexical
+	opaque bool
+}
+
+// resolver resolves the REFs of code evaluated in the global Environment
+type resolver struct {
+	global  *Environment
+	globals map[REF]*GREF
+}
+
+// newResolver creates a resolver for code to be evaluated in sco, along with the lexical scopes that sco is within
+func newResolver(sco interfaces.Scope) (*resolver, *lexical) {
+	r := &resolver{}
+	var scopes []*lexicalScope
+	for {
+		s, ok := sco.(*lexicalScope)
+		if !ok {
+			break
+		}
+		scopes = append(scopes, s)
+		sco = s.parent
+	}
+	var scope *lexical
+	for i := len(scopes) - 1; i >= 0; i-- {
+		scope = &lexical{names: scopes[i].names, parent: scope}
+	}
+	if env, ok := sco.(*Environment); ok && env.parent == nil {
+		r.global = env.globals.env
+		r.globals = map[REF]*GREF{}
+	}
+	return r, scope
+}
+
+func (r *resolver) resolve(code interfaces.Type, scope *lexical) interfaces.Type {
+	switch c := code.(type) {
+	case REF:
+		return r.resolveREF(c, scope)
+	case *EXP:
+		return r.resolveEXP(c, scope)
+	}
+	return code
+}
+
+func (r *resolver) resolveREF(ref REF, scope *lexical) interfaces.Type {
+	depth, opaque := 0, false
+	for s := scope; s != nil; s = s.parent {
+		opaque = opaque || s.opaque
+		for i := len(s.names) - 1; i >= 0; i-- {
+			if s.names[i] == ref {
+				return LREF{Name: ref, Depth: depth, Index: i}
+			}
+		}
+		depth++
+	}
+	if r.global == nil || opaque {
+		return ref
+	}
+	global, ok := r.globals[ref]
+	if !ok {
+		global = &GREF{Name: ref, env: r.global}
+		r.globals[ref] = global
+	}
+	return global
+}
+
+// resolveEXP resolves the arguments of an EXP, introducing a new lexical scope for the body of a fn, let, letfn or
+// loop, or for that of each arity of a fn. Arguments of fn, let, letfn and loop that are not well formed, the bodies
+// of macros, the names given to declare, and quoted code are left as they are. The handler of each catch clause of try
+// is resolved in a new lexical scope binding the exception.
+func (r *resolver) resolveEXP(exp *EXP, scope *lexical) *EXP {
+	function := r.resolve(exp.Function, scope)
+	arguments := make([]interfaces.Type, len(exp.Arguments))
+	copy(arguments, exp.Arguments)
+	resolved := &EXP{Function: function, Arguments: arguments, Pos: exp.Pos, Positions: exp.Positions}
+
+	name, _ := exp.Function.(REF)
+	if _, local := function.(LREF); local {
+		name = ""
+	}
+	switch name {
+	case "fn":
+		if len(arguments) == 2 {
+			if vec, ok := arguments[0].(VEC); ok {
+				if names, err := BoundNames(vec); err == nil {
+					arguments[1] = r.resolve(arguments[1], &lexical{names: names, parent: scope})
+				}
+				break
+			}
+		}
+		for p, arg := range arguments {
+			if arity, ok := arg.(*EXP); ok && len(arity.Arguments) == 1 {
+				if vec, ok := arity.Function.(VEC); ok {
+					if names, err := BoundNames(vec); err == nil {
+						body := r.resolve(arity.Arguments[0], &lexical{names: names, parent: scope})
+						arguments[p] = &EXP{Function: vec, Arguments: []interfaces.Type{body}, Pos: arity.Pos, Positions: arity.Positions}
+					}
+				}
+			}
+		}
+	case "let", "loop":
+		if len(arguments) == 2 {
+			if bindings, ok := arguments[0].(VEC); ok && len(bindings.Vector)%2 == 0 {
+				if bound, ok := bindingNames(bindings); ok {
+					inner := &lexical{parent: scope}
+					values := make([]interfaces.Type, len(bindings.Vector))
+					for i := 0; i < len(values); i += 2 {
+						values[i] = bindings.Vector[i]
+						values[i+1] = r.resolve(bindings.Vector[i+1], inner)
+						inner.names = append(inner.names[:len(inner.names):len(inner.names)], bound[i/2]...)
+					}
+					arguments[0] = VEC{Vector: values, Pos: bindings.Pos, Positions: bindings.Positions}
+					arguments[1] = r.resolve(arguments[1], inner)
+				}
+			}
+		}
+	case "letfn":
+		if len(arguments) == 2 {
+			if definitions, ok := arguments[0].(VEC); ok {
+				if names, ok := letfnNames(definitions); ok {
+					inner := &lexical{names: names, parent: scope}
+					values := make([]interfaces.Type, len(definitions.Vector))
+					for i, definition := range definitions.Vector {
+						d := definition.(*EXP)
+						function := r.resolveEXP(&EXP{Function: REF("fn"), Arguments: d.Arguments}, inner)
+						values[i] = &EXP{Function: d.Function, Arguments: function.Arguments, Pos: d.Pos, Positions: d.Positions}
+					}
+					arguments[0] = VEC{Vector: values, Pos: definitions.Pos, Positions: definitions.Positions}
+					arguments[1] = r.resolve(arguments[1], inner)
+				}
+			}
+		}
+	case "try":
+		for p, arg := range arguments {
+			arguments[p] = r.resolveClause(arg, scope)
+		}
+	case "macro", "quote", "syntax-quote", "declare":
+	case "def":
+		if len(arguments) > 1 {
+			scope.define(arguments[0])
+			arguments[1] = r.resolve(arguments[1], scope)
+		}
+	default:
+		if _, local := function.(LREF); scope != nil && !local {
+			r.define(exp, scope)
+		}
+		for p, arg := range arguments {
+			arguments[p] = r.resolve(arg, scope)
+		}
+	}
+	return resolved
+}
+
+// resolveClause resolves an argument of try. The handler of a catch clause is resolved in a new lexical scope binding
+// the name given to the exception.
+func (r *resolver) resolveClause(code interfaces.Type, scope *lexical) interfaces.Type {
+	kind, arguments := clauseOf(code)
+	if kind != "catch" && kind != "finally" {
+		return r.resolve(code, scope)
+	}
+	exp := code.(*EXP)
+	resolved := make([]interfaces.Type, len(arguments))
+	for p, arg := range arguments {
+		resolved[p] = r.resolve(arg, scope)
+	}
+	if name, ok := itemAfter(arguments, 0).(REF); ok && kind == "catch" && len(arguments) == 3 {
+		resolved[1] = name
+		resolved[2] = r.resolve(arguments[2], &lexical{names: []REF{name}, parent: scope})
+	}
+	return &EXP{Function: exp.Function, Arguments: resolved, Pos: exp.Pos, Positions: exp.Positions}
+}
+
+// define adds the name that a def binds within a fn, let or loop to its lexical scope, so that the code following it
+// refers to the variable it binds. At the top level, where scope is nil, def binds a global variable instead.
+func (scope *lexical) define(name interfaces.Type) {
+	if ref, ok := name.(REF); ok && scope != nil {
+		scope.names = append(scope.names[:len(scope.names):len(scope.names)], ref)
+	}
+}
+
+// define adds the name bound by exp to scope if it calls defn or defmacro. Any other macro is not run to find out what
+// it defines, but leaves scope opaque so that the names following it are looked up when evaluated.
+func (r *resolver) define(exp *EXP, scope *lexical) {
+	name, ok := exp.Function.(REF)
+	if !ok || scope == nil {
+		return
+	}
+	if (name == "defn" || name == "defmacro") && len(exp.Arguments) > 0 {
+		scope.define(exp.Arguments[0])
+	} else if r.global != nil {
+		value, _ := r.global.ResolveRef(name)
+		if _, macro := value.(interfaces.Expandable); macro {
+			scope.opaque = true
+		}
+	}
+}
+
+// letfnNames returns the names of the functions defined by letfn if they are all well formed
+func letfnNames(definitions VEC) ([]REF, bool) {
+	names := make([]REF, 0, len(definitions.Vector))
+	for _, definition := range definitions.Vector {
+		name, ok := letfnName(definition)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+// bindingNames returns the names bound by the pattern of each pair of bindings, if they are all well formed
+func bindingNames(bindings VEC) ([][]REF, bool) {
+	names := make([][]REF, 0, len(bindings.Vector)/2)
+	for i := 0; i < len(bindings.Vector); i += 2 {
+		bound, err := BoundNames(bindings.Vector[i])
+		if err != nil {
+			return nil, false
+		}
+		names = append(names, bound)
+	}
+	return names, true
+}
+
+// unresolve returns a copy of code with each LREF and GREF replaced by the REF it was resolved from, so that it can be
+// substituted into a macro, where the lexical addresses would no longer hold
+func unresolve(code interfaces.Type) interfaces.Type {
+	switch c := code.(type) {
+	case LREF:
+		return c.Name
+	case *GREF:
+		return c.Name
+	case *EXP:
+		return &EXP{Function: unresolve(c.Function), Arguments: unresolveAll(c.Arguments), Pos: c.Pos, Positions: c.Positions}
+	case VEC:
+		return VEC{Vector: unresolveAll(c.Vector), Pos: c.Pos, Positions: c.Positions}
+	}
+	return code
+}
+
+func unresolveAll(code []interfaces.Type) []interfaces.Type {
+	result := make([]interfaces.Type, len(code))
+	for i, c := range code {
+		result[i] = unresolve(c)
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Resolve_FnArgumentsResolveToLexicalAddress(t *testing.T) {
+	//given
+	body := EXPBuild(REF("+")).withArgs(REF("a"), REF("b")).build()
+	exp := EXPBuild(REF("fn")).withArgs(VEC{Vector: []interfaces.Type{REF("a"), REF("b")}}, body).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment.NewChildScope()).(*EXP)
+
+	//then
+	resolvedBody := resolved.Arguments[1].(*EXP)
+	assert.Equal(t, REF("+"), resolvedBody.Function)
+	assert.Equal(t, []interfaces.Type{LREF{Name: "a", Depth: 0, Index: 0}, LREF{Name: "b", Depth: 0, Index: 1}}, resolvedBody.Arguments)
+}
+
+func Test_Resolve_EachArityOfFnHasItsOwnScope(t *testing.T) {
+	//given
+	one := EXPBuild(VEC{Vector: []interfaces.Type{REF("b")}}).withArgs(REF("b")).build()
+	two := EXPBuild(VEC{Vector: []interfaces.Type{REF("a"), REF("b")}}).withArgs(REF("b")).build()
+	exp := EXPBuild(REF("fn")).withArgs(one, two).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment.NewChildScope()).(*EXP)
+
+	//then
+	assert.Equal(t, LREF{Name: "b", Depth: 0, Index: 0}, resolved.Arguments[0].(*EXP).Arguments[0])
+	assert.Equal(t, LREF{Name: "b", Depth: 0, Index: 1}, resolved.Arguments[1].(*EXP).Arguments[0])
+	assert.Equal(t, REF("b"), one.Arguments[0])
+}
+
+func Test_Resolve_DefWithinFnBindsLocalVariable(t *testing.T) {
+	//given
+	def := EXPBuild(REF("def")).withArgs(REF("b"), REF("a")).build()
+	body := EXPBuild(REF("do")).withArgs(def, REF("b")).build()
+	exp := EXPBuild(REF("fn")).withArgs(VEC{Vector: []interfaces.Type{REF("a")}}, body).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment.NewChildScope()).(*EXP)
+
+	//then
+	resolvedBody := resolved.Arguments[1].(*EXP)
+	assert.Equal(t, LREF{Name: "a", Depth: 0, Index: 0}, resolvedBody.Arguments[0].(*EXP).Arguments[1])
+	assert.Equal(t, LREF{Name: "b", Depth: 0, Index: 1}, resolvedBody.Arguments[1])
+}
+
+func Test_Resolve_LetfnFunctionsSeeEachOther(t *testing.T) {
+	//given
+	even := EXPBuild(REF("ev")).withArgs(VEC{Vector: []interfaces.Type{REF("n")}}, EXPBuild(REF("od")).withArgs(REF("n")).build()).build()
+	odd := EXPBuild(REF("od")).withArgs(VEC{Vector: []interfaces.Type{REF("n")}}, EXPBuild(REF("ev")).withArgs(REF("n")).build()).build()
+	exp := EXPBuild(REF("letfn")).withArgs(VEC{Vector: []interfaces.Type{even, odd}}, REF("od")).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment.NewChildScope()).(*EXP)
+
+	//then
+	resolvedEven := resolved.Arguments[0].(VEC).Vector[0].(*EXP)
+	assert.Equal(t, REF("ev"), resolvedEven.Function)
+	call := resolvedEven.Arguments[1].(*EXP)
+	assert.Equal(t, LREF{Name: "od", Depth: 1, Index: 1}, call.Function)
+	assert.Equal(t, LREF{Name: "n", Depth: 0, Index: 0}, call.Arguments[0])
+	assert.Equal(t, LREF{Name: "od", Depth: 0, Index: 1}, resolved.Arguments[1])
+}
+
+func Test_Resolve_LetBindingsSeeEarlierBindingsAndEnclosingScopes(t *testing.T) {
+	//given
+	bindings := VEC{Vector: []interfaces.Type{REF("b"), REF("a"), REF("a"), REF("b")}}
+	let := EXPBuild(REF("let")).withArgs(bindings, REF("a")).build()
+	exp := EXPBuild(REF("fn")).withArgs(VEC{Vector: []interfaces.Type{REF("a")}}, let).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment.NewChildScope()).(*EXP)
+
+	//then
+	resolvedLet := resolved.Arguments[1].(*EXP)
+	resolvedBindings := resolvedLet.Arguments[0].(VEC).Vector
+	assert.Equal(t, LREF{Name: "a", Depth: 1, Index: 0}, resolvedBindings[1])
+	assert.Equal(t, LREF{Name: "b", Depth: 0, Index: 0}, resolvedBindings[3])
+	assert.Equal(t, LREF{Name: "a", Depth: 0, Index: 1}, resolvedLet.Arguments[1])
+}
+
+func Test_Resolve_FreeREFsAreGlobalOnlyInGlobalEnvironment(t *testing.T) {
+	//given
+	exp := EXPBuild(REF("+")).withArgs(REF("a")).build()
+
+	//when
+	global := Resolve(exp, GlobalEnvironment).(*EXP)
+	child := Resolve(exp, GlobalEnvironment.NewChildScope()).(*EXP)
+
+	//then
+	assert.IsType(t, &GREF{}, global.Function)
+	assert.IsType(t, &GREF{}, global.Arguments[0])
+	assert.Equal(t, REF("+"), child.Function)
+	assert.Equal(t, REF("a"), child.Arguments[0])
+}
+
+func Test_Resolve_MacroBodyIsLeftUnresolved(t *testing.T) {
+	//given
+	body := EXPBuild(REF("+")).withArgs(REF("a"), I(1)).build()
+	exp := EXPBuild(REF("macro")).withArgs(VEC{Vector: []interfaces.Type{REF("a")}}, body).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment).(*EXP)
+
+	//then
+	assert.Equal(t, body, resolved.Arguments[1])
+}
+
+func Test_Resolve_GREFSeesGlobalsDefinedAfterItIsCached(t *testing.T) {
+	//given
+	GlobalEnvironment.CreateRef(REF("resolvedglobal"), I(1))
+	ref := Resolve(REF("resolvedglobal"), GlobalEnvironment).(interfaces.Evaluatable)
+	before, _ := ref.Evaluate(GlobalEnvironment)
+
+	//when
+	GlobalEnvironment.CreateRef(REF("resolvedglobal"), I(2))
+	after, err := ref.Evaluate(GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(1), before)
+	assert.Equal(t, I(2), after)
+}
+
+func Test_Resolve_FnEvaluatesWithLexicalAddresses(t *testing.T) {
+	//given
+	adder := EXPBuild(REF("fn")).withArgs(
+		VEC{Vector: []interfaces.Type{REF("a")}},
+		EXPBuild(REF("fn")).withArgs(
+			VEC{Vector: []interfaces.Type{REF("b")}},
+			EXPBuild(REF("+")).withArgs(REF("a"), REF("b")).build(),
+		).build(),
+	).build()
+	exp := EXPBuild(EXPBuild(adder).withArgs(I(2)).build()).withArgs(I(5)).build()
+
+	//when
+	result, err := Evaluate(exp, GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(7), result)
+}
+
+func Test_Resolve_MacroArgumentsAreLookedUpByName(t *testing.T) {
+	//given
+	GlobalEnvironment.CreateRef(REF("wrapinlet"), MAC{
+		Arguments:  VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: syntaxQuoted("let", VEC{Vector: []interfaces.Type{REF("y"), I(2)}}, unquoteOf("a")),
+	})
+	body := EXPBuild(REF("wrapinlet")).withArgs(REF("x")).build()
+	exp := EXPBuild(REF("let")).withArgs(VEC{Vector: []interfaces.Type{REF("x"), I(1)}}, body).build()
+
+	//when
+	result, err := Evaluate(exp, GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(1), result)
+}
+
+func Test_Resolve_MacroDefiningLocalIsExpandedOnlyWhenEvaluated(t *testing.T) {
+	//given
+	expansions := NewATOM(I(0))
+	GlobalEnvironment.CreateRef(REF("defcounted"), MAC{
+		Arguments: VEC{Vector: []interfaces.Type{REF("a")}},
+		Expression: EXPBuild(REF("do")).withArgs(
+			EXPBuild(REF("swap!")).withArgs(expansions, REF("+"), I(1)).build(),
+			syntaxQuoted("def", unquoteOf("a"), I(1)),
+		).build(),
+	})
+	body := EXPBuild(REF("do")).withArgs(EXPBuild(REF("defcounted")).withArgs(REF("x")).build(), REF("x")).build()
+	exp := EXPBuild(REF("let")).withArgs(VEC{Vector: []interfaces.Type{REF("y"), I(2)}}, body).build()
+
+	//when
+	resolved := Resolve(exp, GlobalEnvironment).(*EXP)
+	resolvedExpansions := expansions.Deref()
+	result, err := Evaluate(exp, GlobalEnvironment)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, I(0), resolvedExpansions)
+	assert.Equal(t, REF("x"), resolved.Arguments[1].(*EXP).Arguments[1])
+	assert.Equal(t, I(1), result)
+	assert.Equal(t, I(1), expansions.Deref())
+}
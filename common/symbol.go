@@ -75,6 +75,22 @@ func (m *MAP) lookup(k interfaces.Equalable) (interfaces.Value, bool) {
 	return NILL, false
 }
 
+// entries returns every entry of the MAP, including those it was associated from that have not been replaced
+func (m *MAP) entries() map[interfaces.Equalable]interfaces.Value {
+	if m.parent == nil {
+		return m.store
+	}
+	result := m.parent.entries()
+	merged := make(map[interfaces.Equalable]interfaces.Value, len(result)+len(m.store))
+	for k, v := range result {
+		merged[k] = v
+	}
+	for k, v := range m.store {
+		merged[k] = v
+	}
+	return merged
+}
+
 func initialiseMAP(arguments []interfaces.Value) (*MAP, error) {
 	count := len(arguments)
 	if count%2 > 0 {
@@ -82,7 +98,11 @@ func initialiseMAP(arguments []interfaces.Value) (*MAP, error) {
 	}
 	mp := &MAP{map[interfaces.Equalable]interfaces.Value{}, nil}
 	for i := 0; i < count; i += 2 {
-		mp.store[arguments[i].(interfaces.Equalable)] = arguments[i+1]
+		key, ok := arguments[i].(interfaces.Equalable)
+		if !ok {
+			return nil, fmt.Errorf("MAP Initialise : expected keys that can be compared, recieved %v", arguments[i])
+		}
+		mp.store[key] = arguments[i+1]
 	}
 	return mp, nil
 }
@@ -94,7 +114,11 @@ func (m *MAP) associate(arguments []interfaces.Value) (*MAP, error) {
 	}
 	mp := &MAP{map[interfaces.Equalable]interfaces.Value{}, m}
 	for i := 0; i < count; i += 2 {
-		mp.store[arguments[i].(interfaces.Equalable)] = arguments[i+1]
+		key, ok := arguments[i].(interfaces.Equalable)
+		if !ok {
+			return nil, fmt.Errorf("MAP Initialise : expected keys that can be compared, recieved %v", arguments[i])
+		}
+		mp.store[key] = arguments[i+1]
 	}
 	return mp, nil
 }
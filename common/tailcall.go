@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/interfaces"
+)
+
+// tailCall is returned in place of a Value by an Expression in tail position, such as the body of a FN or the chosen
+// branch of an if. Rather than evaluating it immediately, and growing the Go stack, it is handed back to the nearest
+// trampoline which evaluates it in a loop.
+type tailCall struct {
+	evaluatable interfaces.Evaluatable
+	scope       interfaces.Scope
+}
+
+// IsType for tailCall
+func (tc *tailCall) IsType() {}
+
+// IsValue for tailCall
+func (tc *tailCall) IsValue() {}
+
+// String representation of tailCall
+func (tc *tailCall) String() string {
+	return fmt.Sprintf("TAILCALL(%v)", tc.evaluatable)
+}
+
+func (tc *tailCall) evaluate() (interfaces.Value, error) {
+	if exp, ok := tc.evaluatable.(*EXP); ok {
+		return exp.evaluate(tc.scope, true)
+	}
+	return tc.evaluatable.Evaluate(tc.scope)
+}
+
+// tailAppliable is implemented by Appliables that can return a tailCall rather than evaluating their result
+type tailAppliable interface {
+	applyTail([]interfaces.Type, interfaces.Scope) (interfaces.Value, error)
+}
+
+// trampoline evaluates tailCalls until a Value is produced, failing if that Value came from a recur that was not
+// returned to a loop
+func trampoline(result interfaces.Value, err error) (interfaces.Value, error) {
+	result, err = bounce(result, err)
+	if _, ok := result.(*recurValue); ok {
+		return NILL, errRecurNotInTailPosition
+	}
+	return result, err
+}
+
+// bounce evaluates tailCalls until a Value is produced
+func bounce(result interfaces.Value, err error) (interfaces.Value, error) {
+	for err == nil {
+		tc, ok := result.(*tailCall)
+		if !ok {
+			return result, nil
+		}
+		result, err = tc.evaluate()
+	}
+	return NILL, err
+}
+
+// evaluateTail defers evaluation of an Expression in tail position to the trampoline, other values are evaluated
+// straight away
+func evaluateTail(value interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	if exp, ok := value.(*EXP); ok {
+		return &tailCall{exp, sco}, nil
+	}
+	return evaluateToValue(value, sco)
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"github.com/mikeyhu/glipso/interfaces"
+	"strings"
 )
 
 // B (Boolean)
@@ -33,8 +34,11 @@ func (b B) Equals(o interfaces.Equalable) interfaces.Value {
 }
 
 // VEC is a Vector or array
+// Pos and Positions are set when the VEC is parsed, Positions holding the location of each item in the Vector
 type VEC struct {
-	Vector []interfaces.Type
+	Vector    []interfaces.Type
+	Pos       Pos
+	Positions []Pos
 }
 
 // IsType for VEC
@@ -73,7 +77,10 @@ func (s S) String() string {
 
 //CompareTo for String
 func (s S) CompareTo(o interfaces.Comparable) (int, error) {
-	panic("not implemented")
+	if other, ok := o.(S); ok {
+		return strings.Compare(string(s), string(other)), nil
+	}
+	return 0, fmt.Errorf("CompareTo : Cannot compare %v to %v", s, o)
 }
 
 // NIL generally acts as a return type when a function performs a side effect
@@ -17,7 +17,7 @@ func (e EXPBuilder) withArgs(args ...interfaces.Type) EXPBuilder {
 }
 
 func (e EXPBuilder) build() *EXP {
-	return &EXP{e.function, e.arguments}
+	return &EXP{Function: e.function, Arguments: e.arguments}
 }
 
 type FNBuilder struct {
@@ -41,7 +41,7 @@ func (f FNBuilder) withEXPBuilder(expression EXPBuilder) FNBuilder {
 
 func (f FNBuilder) build() FN {
 	return FN{
-		VEC{f.arguments},
-		f.expression.build(),
+		Arguments:  VEC{Vector: f.arguments},
+		Expression: f.expression.build(),
 	}
 }
@@ -0,0 +1,136 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+	"strconv"
+	"strings"
+)
+
+// Op is an operation performed by the vm
+type Op uint8
+
+// Operations understood by the vm. Arg refers to the operand of the Instruction.
+const (
+	OpConst       Op = iota // push Constants[arg]
+	OpNil                   // push NILL
+	OpLocal                 // push local slot arg
+	OpSetLocal              // pop into local slot arg
+	OpUpvalue               // push captured value arg
+	OpGlobal                // push the value of Names[arg] from scope
+	OpFunction              // push the value of Names[arg] from scope, when in function position
+	OpPop                   // discard the top of the stack
+	OpJump                  // continue from instruction arg
+	OpJumpIfFalse           // pop a boolean and continue from instruction arg if it is false
+	OpGuard                 // if the top of the stack is a macro or lazy function, evaluate Fallbacks[arg] instead
+	OpCall                  // apply the function below arg arguments on the stack
+	OpTailCall              // as OpCall, reusing the current frame when applying compiled functions
+	OpApply                 // apply the function below arg values and a list to the values followed by the list's items
+	OpTailApply             // as OpApply, reusing the current frame when applying compiled functions
+	OpReturn                // return the top of the stack from the current function
+	OpClosure               // create a function from Protos[arg] capturing its Upvalues
+	OpLazyPair              // pop a head, pushing a lazy pair with Protos[arg-1] as its tail, or no tail when arg is 0
+	OpDef                   // pop a value and define it as Names[arg]
+	OpEval                  // evaluate Fallbacks[arg] with the tree walking evaluator
+)
+
+var opNames = [...]string{
+	"CONST", "NIL", "LOCAL", "SETLOCAL", "UPVALUE", "GLOBAL", "FUNCTION", "POP", "JUMP", "JUMPIFFALSE",
+	"GUARD", "CALL", "TAILCALL", "APPLY", "TAILAPPLY", "RETURN", "CLOSURE", "LAZYPAIR", "DEF", "EVAL",
+}
+
+// String representation of Op
+func (op Op) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("OP(%d)", op)
+}
+
+// Instruction packs an Op into the low 8 bits and its operand into the remaining 24 bits
+type Instruction uint32
+
+const maxArg = 1<<24 - 1
+
+func instruction(op Op, arg int) Instruction {
+	return Instruction(uint32(op) | uint32(arg)<<8)
+}
+
+// Op returns the operation of the Instruction
+func (i Instruction) Op() Op {
+	return Op(i & 0xff)
+}
+
+// Arg returns the operand of the Instruction
+func (i Instruction) Arg() int {
+	return int(i >> 8)
+}
+
+// String representation of Instruction
+func (i Instruction) String() string {
+	return fmt.Sprintf("%v %d", i.Op(), i.Arg())
+}
+
+// Var locates a variable visible to a function, either in one of its local slots or amongst its captured upvalues
+type Var struct {
+	Upvalue bool
+	Index   int
+}
+
+// Fallback is code that the vm hands to the tree walking evaluator, along with the variables visible to it so that
+// an Environment can be created for its evaluation. Code has been through common.Resolve.
+type Fallback struct {
+	Code   *common.EXP
+	Names  []common.REF
+	Vars   []Var
+	Resume int
+}
+
+// Proto is a compiled function. Code and Positions run in parallel, so an error at any Instruction can be reported
+// against the source that produced it. Names holds the REFs of globals, resolved by common.Resolve so that those of a
+// global Environment cache their values. A Variadic Proto takes any number of arguments beyond its Arity, receiving
+// them as a list in the slot after the others.
+type Proto struct {
+	Name      string
+	Arity     int
+	Variadic  bool
+	Locals    int
+	Code      []Instruction
+	Positions []common.Pos
+	Constants []interfaces.Value
+	Names     []interfaces.Type
+	Protos    []*Proto
+	Fallbacks []*Fallback
+	Upvalues  []Var
+}
+
+// String disassembles the Proto and any Protos it contains
+func (p *Proto) String() string {
+	out := strings.Builder{}
+	p.disassemble(&out)
+	return out.String()
+}
+
+func (p *Proto) disassemble(out *strings.Builder) {
+	arity := strconv.Itoa(p.Arity)
+	if p.Variadic {
+		arity += "+"
+	}
+	fmt.Fprintf(out, "%s arity:%s locals:%d upvalues:%v\n", p.Name, arity, p.Locals, p.Upvalues)
+	for ip, ins := range p.Code {
+		fmt.Fprintf(out, "%4d %-12v %d", ip, ins.Op(), ins.Arg())
+		switch ins.Op() {
+		case OpConst:
+			fmt.Fprintf(out, "\t%v", p.Constants[ins.Arg()])
+		case OpGlobal, OpFunction, OpDef:
+			fmt.Fprintf(out, "\t%v", p.Names[ins.Arg()])
+		case OpGuard, OpEval:
+			fmt.Fprintf(out, "\t%v", p.Fallbacks[ins.Arg()].Code)
+		}
+		out.WriteString("\n")
+	}
+	for _, proto := range p.Protos {
+		proto.disassemble(out)
+	}
+}
@@ -0,0 +1,377 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+)
+
+// Compile compiles code into a Proto that takes no arguments. Macros and special forms are recognised by resolving
+// the function of each expression against scope, anything that cannot be compiled is left for the vm to hand to the
+// tree walking evaluator.
+func Compile(code interfaces.Type, scope interfaces.Scope) (*Proto, error) {
+	f := newFunction(nil, "main", scope)
+	if err := f.compileBody(code, positionOf(code), context{tail: true}); err != nil {
+		return nil, err
+	}
+	if f.overflow {
+		return nil, errors.New("compile : too many constants or instructions")
+	}
+	return f.proto, nil
+}
+
+// errRecur is returned when recur is found where the compiler cannot jump back to a loop. It is not reported, instead
+// the enclosing loop or function is compiled as a Fallback so the tree walking evaluator can report it when evaluated.
+var errRecur = errors.New("compile : recur outside of tail position of loop")
+
+// errLocalDef is returned when def is found within a fn, let or loop, where it binds a variable of the scope that the
+// tree walking evaluator creates. The enclosing let, loop or fn is compiled as a Fallback instead.
+var errLocalDef = errors.New("compile : def within fn, let or loop")
+
+// context describes the position of the code being compiled. tail is true when the result of the code will be
+// returned from the function being compiled, loop is set when recur may jump back to an enclosing loop.
+type context struct {
+	tail bool
+	loop *loopTarget
+	name string
+}
+
+var nonTail = context{}
+
+type loopTarget struct {
+	slots []int
+	start int
+}
+
+type binding struct {
+	name common.REF
+	slot int
+}
+
+// function holds the state of a Proto while it is being compiled
+type function struct {
+	parent    *function
+	scope     interfaces.Scope
+	proto     *Proto
+	bindings  []binding
+	upvalues  []common.REF
+	slots     int
+	loops     int
+	constants map[interfaces.Value]int
+	names     map[common.REF]int
+	overflow  bool
+	fn        bool
+}
+
+func newFunction(parent *function, name string, scope interfaces.Scope) *function {
+	return &function{
+		parent:    parent,
+		scope:     scope,
+		proto:     &Proto{Name: name},
+		constants: map[interfaces.Value]int{},
+		names:     map[common.REF]int{},
+	}
+}
+
+// compileBody compiles the body of a function, falling back to the tree walking evaluator if it uses recur incorrectly.
+// Exceeding a quota while expanding macros is returned instead, as the tree walking evaluator would exceed it again.
+func (f *function) compileBody(code interfaces.Type, pos common.Pos, ctx context) error {
+	if err := f.compile(code, pos, ctx); err != nil {
+		var quotaErr *common.QuotaError
+		exp, ok := code.(*common.EXP)
+		if !ok || err == errLocalDef || errors.As(err, &quotaErr) {
+			return err
+		}
+		f.truncate(0)
+		f.release(0)
+		f.emitFallback(OpEval, exp)
+	}
+	f.emit(OpReturn, 0, pos)
+	return nil
+}
+
+func (f *function) compile(code interfaces.Type, pos common.Pos, ctx context) error {
+	switch c := code.(type) {
+	case common.REF:
+		f.compileREF(c, pos, OpGlobal)
+	case *common.EXP:
+		return f.compileEXP(c, ctx)
+	case common.NIL:
+		f.emit(OpNil, 0, pos)
+	case interfaces.Value:
+		f.emit(OpConst, f.constant(c), pos)
+	default:
+		return fmt.Errorf("compile : unable to compile %v", code)
+	}
+	return nil
+}
+
+func (f *function) compileREF(ref common.REF, pos common.Pos, global Op) {
+	if v, ok := f.resolve(ref); ok {
+		f.emitVar(v, pos)
+	} else {
+		f.emit(global, f.name(ref), pos)
+	}
+}
+
+func (f *function) compileEXP(exp *common.EXP, ctx context) error {
+	guard := true
+	if ref, ok := exp.Function.(common.REF); ok {
+		if _, local := f.resolve(ref); !local {
+			if value, found := f.scope.ResolveRef(ref); found {
+				switch v := value.(type) {
+				case interfaces.Expandable:
+					// the expansion is compiled within the same Depth, so that macros expanding to calls of
+					// themselves are bounded
+					evaluation := common.EvaluationOf(f.scope)
+					if err := evaluation.Enter(); err != nil {
+						return err
+					}
+					defer evaluation.Leave(1)
+					expanded, err := v.Expand(exp.Arguments, f.scope)
+					if err != nil {
+						return f.fallback(exp)
+					}
+					return f.compile(expanded, exp.Pos, ctx)
+				case common.FI:
+					if v.Lazy() {
+						if special, ok := specialForms[v.Name()]; ok && !v.Host() {
+							return special(f, exp, ctx)
+						}
+						return f.fallback(exp)
+					}
+				}
+				guard = false
+			}
+		}
+	}
+	return f.compileCall(exp, ctx, guard)
+}
+
+// compileCall compiles the application of a function. When the function is not known to be eager it is guarded, so
+// that macros and lazy functions found when evaluated are handed to the tree walking evaluator with the arguments
+// unevaluated.
+func (f *function) compileCall(exp *common.EXP, ctx context, guard bool) error {
+	if ref, ok := exp.Function.(common.REF); ok {
+		f.compileREF(ref, positionAt(exp, 0), OpFunction)
+	} else if err := f.compile(exp.Function, positionAt(exp, 0), nonTail); err != nil {
+		return err
+	}
+	var fb *Fallback
+	if guard {
+		fb = f.emitFallback(OpGuard, exp)
+	}
+	for i, arg := range exp.Arguments {
+		if err := f.compile(arg, positionAt(exp, i+1), nonTail); err != nil {
+			return err
+		}
+	}
+	if ctx.tail {
+		f.emit(OpTailCall, len(exp.Arguments), exp.Pos)
+	} else {
+		f.emit(OpCall, len(exp.Arguments), exp.Pos)
+	}
+	if fb != nil {
+		fb.Resume = len(f.proto.Code)
+	}
+	return nil
+}
+
+// local returns true when the code being compiled is within a fn, let or loop
+func (f *function) local() bool {
+	for fn := f; fn != nil; fn = fn.parent {
+		if fn.fn || len(fn.bindings) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fallback compiles an expression to be evaluated by the tree walking evaluator. If it contains a recur that belongs
+// to a loop being compiled then the loop itself must fall back.
+func (f *function) fallback(exp *common.EXP) error {
+	if f.loops > 0 && containsRecur(exp) {
+		return errRecur
+	}
+	f.emitFallback(OpEval, exp)
+	return nil
+}
+
+func (f *function) emitFallback(op Op, exp *common.EXP) *Fallback {
+	fb := &Fallback{Code: common.Resolve(exp, nil).(*common.EXP)}
+	for _, name := range f.visible() {
+		v, _ := f.resolve(name)
+		fb.Names = append(fb.Names, name)
+		fb.Vars = append(fb.Vars, v)
+	}
+	f.proto.Fallbacks = append(f.proto.Fallbacks, fb)
+	f.emit(op, len(f.proto.Fallbacks)-1, exp.Pos)
+	return fb
+}
+
+// visible returns the name of every variable visible to the function, including those of enclosing functions
+func (f *function) visible() []common.REF {
+	var names []common.REF
+	seen := map[common.REF]bool{}
+	for fn := f; fn != nil; fn = fn.parent {
+		for _, b := range fn.bindings {
+			if !seen[b.name] {
+				seen[b.name] = true
+				names = append(names, b.name)
+			}
+		}
+	}
+	return names
+}
+
+// resolve finds a variable in the function or an enclosing function, capturing it as an upvalue in the latter case
+func (f *function) resolve(name common.REF) (Var, bool) {
+	for i := len(f.bindings) - 1; i >= 0; i-- {
+		if f.bindings[i].name == name {
+			return Var{Index: f.bindings[i].slot}, true
+		}
+	}
+	for i, up := range f.upvalues {
+		if up == name {
+			return Var{Upvalue: true, Index: i}, true
+		}
+	}
+	if f.parent == nil {
+		return Var{}, false
+	}
+	v, ok := f.parent.resolve(name)
+	if !ok {
+		return Var{}, false
+	}
+	f.proto.Upvalues = append(f.proto.Upvalues, v)
+	f.upvalues = append(f.upvalues, name)
+	return Var{Upvalue: true, Index: len(f.upvalues) - 1}, true
+}
+
+// bind allocates a slot for a new local variable, it is not visible until declared
+func (f *function) bind() int {
+	slot := f.slots
+	f.slots++
+	if f.slots > f.proto.Locals {
+		f.proto.Locals = f.slots
+	}
+	return slot
+}
+
+func (f *function) declare(name common.REF, slot int) {
+	f.bindings = append(f.bindings, binding{name, slot})
+}
+
+// release removes bindings declared since mark, their slots can be reused as closures capture values rather than slots
+func (f *function) release(mark int) {
+	if mark < len(f.bindings) {
+		f.slots = f.bindings[mark].slot
+	}
+	f.bindings = f.bindings[:mark]
+}
+
+func (f *function) emit(op Op, arg int, pos common.Pos) int {
+	if arg > maxArg {
+		f.overflow = true
+	}
+	f.proto.Code = append(f.proto.Code, instruction(op, arg))
+	f.proto.Positions = append(f.proto.Positions, pos)
+	return len(f.proto.Code) - 1
+}
+
+func (f *function) emitVar(v Var, pos common.Pos) {
+	if v.Upvalue {
+		f.emit(OpUpvalue, v.Index, pos)
+	} else {
+		f.emit(OpLocal, v.Index, pos)
+	}
+}
+
+// patch sets the target of a jump to the next instruction
+func (f *function) patch(ip int) {
+	f.proto.Code[ip] = instruction(f.proto.Code[ip].Op(), len(f.proto.Code))
+}
+
+// truncate discards code emitted from ip onwards
+func (f *function) truncate(ip int) {
+	f.proto.Code = f.proto.Code[:ip]
+	f.proto.Positions = f.proto.Positions[:ip]
+}
+
+// constant adds a value to the constant pool, reusing the existing entry for values that can be compared
+func (f *function) constant(value interfaces.Value) int {
+	switch value.(type) {
+	case common.I, common.F, common.S, common.B, common.SYM:
+		if i, ok := f.constants[value]; ok {
+			return i
+		}
+		f.constants[value] = len(f.proto.Constants)
+	}
+	f.proto.Constants = append(f.proto.Constants, value)
+	return len(f.proto.Constants) - 1
+}
+
+func (f *function) name(ref common.REF) int {
+	if i, ok := f.names[ref]; ok {
+		return i
+	}
+	f.names[ref] = len(f.proto.Names)
+	f.proto.Names = append(f.proto.Names, common.Resolve(ref, f.scope))
+	return len(f.proto.Names) - 1
+}
+
+func positionOf(code interfaces.Type) common.Pos {
+	if exp, ok := code.(*common.EXP); ok {
+		return exp.Pos
+	}
+	return common.Pos{}
+}
+
+// positionAt returns the position of the Function (0) or an Argument (1 onwards) of an EXP
+func positionAt(exp *common.EXP, i int) common.Pos {
+	if i < len(exp.Positions) {
+		return exp.Positions[i]
+	}
+	if arg, ok := argumentAt(exp, i).(*common.EXP); ok {
+		return arg.Pos
+	}
+	return exp.Pos
+}
+
+func argumentAt(exp *common.EXP, i int) interfaces.Type {
+	if i == 0 {
+		return exp.Function
+	}
+	return exp.Arguments[i-1]
+}
+
+// containsRecur returns true if code has a recur that is not within a nested loop, fn, macro or quoted code
+func containsRecur(code interfaces.Type) bool {
+	switch c := code.(type) {
+	case *common.EXP:
+		switch c.Function {
+		case common.REF("recur"):
+			return true
+		case common.REF("fn"), common.REF("macro"), common.REF("quote"), common.REF("syntax-quote"):
+			return false
+		case common.REF("loop"):
+			return len(c.Arguments) > 0 && containsRecur(c.Arguments[0])
+		}
+		if containsRecur(c.Function) {
+			return true
+		}
+		for _, arg := range c.Arguments {
+			if containsRecur(arg) {
+				return true
+			}
+		}
+	case common.VEC:
+		for _, item := range c.Vector {
+			if containsRecur(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
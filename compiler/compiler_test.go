@@ -0,0 +1,212 @@
+package compiler
+
+import (
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/mikeyhu/glipso/parser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func compile(t *testing.T, code string, scope interfaces.Scope) *Proto {
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	proto, err := Compile(forms[0], scope)
+	assert.NoError(t, err)
+	return proto
+}
+
+func ops(proto *Proto) []Op {
+	result := make([]Op, len(proto.Code))
+	for i, ins := range proto.Code {
+		result[i] = ins.Op()
+	}
+	return result
+}
+
+func Test_Compile_InbuiltFunctionIsCalledWithoutGuard(t *testing.T) {
+	//when
+	proto := compile(t, "(+ 1 2)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpFunction, OpConst, OpConst, OpTailCall, OpReturn}, ops(proto))
+	assert.Len(t, proto.Names, 1)
+	assert.IsType(t, &common.GREF{}, proto.Names[0])
+	assert.Equal(t, "+", proto.Names[0].String())
+}
+
+func Test_Compile_UnknownFunctionIsGuarded(t *testing.T) {
+	//when
+	proto := compile(t, "(not-yet-defined 1)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpFunction, OpGuard, OpConst, OpTailCall, OpReturn}, ops(proto))
+	assert.Equal(t, 4, proto.Fallbacks[0].Resume)
+}
+
+func Test_Compile_ConstantsArePooled(t *testing.T) {
+	//when
+	proto := compile(t, `(+ 1 1 2 1)`, common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []interfaces.Value{common.I(1), common.I(2)}, proto.Constants)
+}
+
+func Test_Compile_QuoteIsConstant(t *testing.T) {
+	//when
+	proto := compile(t, `'(a 1)`, common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpConst, OpReturn}, ops(proto))
+	assert.Equal(t, common.Quote(&common.EXP{Function: common.REF("a"), Arguments: []interfaces.Type{common.I(1)}}), proto.Constants[0])
+}
+
+func Test_Compile_LetBindsLocalsToSlots(t *testing.T) {
+	//when
+	proto := compile(t, "(let [a 1 b a] b)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpConst, OpSetLocal, OpLocal, OpSetLocal, OpLocal, OpReturn}, ops(proto))
+	assert.Equal(t, 0, proto.Code[2].Arg())
+	assert.Equal(t, 1, proto.Code[4].Arg())
+	assert.Equal(t, 2, proto.Locals)
+	assert.Empty(t, proto.Names)
+}
+
+func Test_Compile_IfJumpsOverBranches(t *testing.T) {
+	//when
+	proto := compile(t, "(if true 1 2)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpConst, OpJumpIfFalse, OpConst, OpJump, OpConst, OpReturn}, ops(proto))
+	assert.Equal(t, 4, proto.Code[1].Arg())
+	assert.Equal(t, 5, proto.Code[3].Arg())
+}
+
+func Test_Compile_FnResolvesArgumentsToSlots(t *testing.T) {
+	//when
+	proto := compile(t, "(def add (fn [a b] (+ a b)))", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpClosure, OpDef, OpReturn}, ops(proto))
+	fn := proto.Protos[0]
+	assert.Equal(t, "add", fn.Name)
+	assert.Equal(t, 2, fn.Arity)
+	assert.Equal(t, []Op{OpFunction, OpLocal, OpLocal, OpTailCall, OpReturn}, ops(fn))
+}
+
+func Test_Compile_VariadicFnBindsRestToSlotAfterArguments(t *testing.T) {
+	//when
+	proto := compile(t, "(fn [a & rest] (cons a rest))", common.GlobalEnvironment)
+
+	//then
+	fn := proto.Protos[0]
+	assert.Equal(t, 1, fn.Arity)
+	assert.True(t, fn.Variadic)
+	assert.Equal(t, 2, fn.Locals)
+	assert.Equal(t, []Op{OpFunction, OpLocal, OpLocal, OpTailCall, OpReturn}, ops(fn))
+}
+
+func Test_Compile_FnCapturesVariablesOfEnclosingScope(t *testing.T) {
+	//when
+	proto := compile(t, "(let [a 1] (fn [b] (+ a b)))", common.GlobalEnvironment)
+
+	//then
+	fn := proto.Protos[0]
+	assert.Equal(t, []Var{{Index: 0}}, fn.Upvalues)
+	assert.Equal(t, []Op{OpFunction, OpUpvalue, OpLocal, OpTailCall, OpReturn}, ops(fn))
+}
+
+func Test_Compile_LoopJumpsBackToStartOfBody(t *testing.T) {
+	//when
+	proto := compile(t, "(loop [i 0] (if (< i 10) (recur (+ i 1)) i))", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{
+		OpConst, OpSetLocal,
+		OpFunction, OpLocal, OpConst, OpCall, OpJumpIfFalse,
+		OpFunction, OpLocal, OpConst, OpCall, OpSetLocal, OpJump,
+		OpJump, OpLocal, OpReturn,
+	}, ops(proto))
+	assert.Equal(t, 2, proto.Code[12].Arg())
+}
+
+func Test_Compile_RecurOutsideTailPositionFallsBack(t *testing.T) {
+	//when
+	proto := compile(t, "(loop [i 0] (+ 1 (recur i)))", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpEval, OpReturn}, ops(proto))
+}
+
+func Test_Compile_RecurWithinFnFallsBackForWholeFn(t *testing.T) {
+	//when
+	proto := compile(t, "(do (fn [a] (recur a)) 1)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpEval, OpPop, OpConst, OpReturn}, ops(proto))
+	assert.Equal(t, "(fn [a] (recur a))", proto.Fallbacks[0].Code.String())
+}
+
+func Test_Compile_DefWithinFnOrLetFallsBack(t *testing.T) {
+	//when
+	proto := compile(t, "(do (fn [a] (def b a)) (let [c 1] (def d c)) (def e 1))", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpEval, OpPop, OpEval, OpPop, OpConst, OpDef, OpReturn}, ops(proto))
+	assert.Equal(t, "(fn [a] (def b a))", proto.Fallbacks[0].Code.String())
+	assert.Equal(t, "(let [c 1] (def d c))", proto.Fallbacks[1].Code.String())
+}
+
+func Test_Compile_MalformedSpecialFormFallsBack(t *testing.T) {
+	//when
+	proto := compile(t, "(if true 1)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpEval, OpReturn}, ops(proto))
+}
+
+func Test_Compile_FallbackRecordsVisibleVariables(t *testing.T) {
+	//when
+	proto := compile(t, "(let [a 1] (fn [b] (if b)))", common.GlobalEnvironment)
+
+	//then
+	fb := proto.Protos[0].Fallbacks[0]
+	assert.Equal(t, []common.REF{"b", "a"}, fb.Names)
+	assert.Equal(t, []Var{{Index: 0}, {Upvalue: true, Index: 0}}, fb.Vars)
+}
+
+func Test_Compile_MacroIsExpandedWhenKnown(t *testing.T) {
+	//given
+	env := common.GlobalEnvironment.NewChildScope()
+	forms, err := parser.Parse("(macro [x] `(+ ~x 1))")
+	assert.NoError(t, err)
+	inc, err := common.Evaluate(forms[0], env)
+	assert.NoError(t, err)
+	env.CreateRef(common.REF("inc"), inc)
+
+	//when
+	proto := compile(t, "(inc 2)", env)
+
+	//then
+	assert.Equal(t, []Op{OpFunction, OpConst, OpConst, OpTailCall, OpReturn}, ops(proto))
+}
+
+func Test_Compile_LazyPairTailIsCompiledAsProto(t *testing.T) {
+	//when
+	proto := compile(t, "(let [a 1] (lazypair a (+ a 1)))", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, []Op{OpConst, OpSetLocal, OpLocal, OpLazyPair, OpReturn}, ops(proto))
+	assert.Equal(t, 1, proto.Code[3].Arg())
+	assert.Equal(t, []Var{{Index: 0}}, proto.Protos[0].Upvalues)
+}
+
+func Test_Compile_PositionsAreRecordedForEachInstruction(t *testing.T) {
+	//when
+	proto := compile(t, "(+ 1\n   missing)", common.GlobalEnvironment)
+
+	//then
+	assert.Equal(t, len(proto.Code), len(proto.Positions))
+	assert.Equal(t, "2:4", proto.Positions[2].String())
+}
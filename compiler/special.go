@@ -0,0 +1,282 @@
+package compiler
+
+import (
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+)
+
+// specialForm compiles an expression applying one of the lazy inbuilt functions. Expressions that are not well formed
+// fall back to the tree walking evaluator so that it reports the error when they are evaluated.
+type specialForm func(f *function, exp *common.EXP, ctx context) error
+
+var specialForms map[string]specialForm
+
+func init() {
+	specialForms = map[string]specialForm{
+		"apply":    compileApply,
+		"def":      compileDef,
+		"do":       compileDo,
+		"fn":       compileFn,
+		"if":       compileIf,
+		"lazypair": compileLazyPair,
+		"let":      compileLet,
+		"loop":     compileLoop,
+		"quote":    compileQuote,
+		"recur":    compileRecur,
+	}
+}
+
+// compileApply compiles the function and any arguments preceding the list, the number of which OpApply is given, so
+// that the items of the list can be spread after them
+func compileApply(f *function, exp *common.EXP, ctx context) error {
+	if len(exp.Arguments) < 2 {
+		return f.fallback(exp)
+	}
+	if ref, ok := exp.Arguments[0].(common.REF); ok {
+		f.compileREF(ref, positionAt(exp, 1), OpFunction)
+	} else if err := f.compile(exp.Arguments[0], positionAt(exp, 1), nonTail); err != nil {
+		return err
+	}
+	for i, arg := range exp.Arguments[1:] {
+		if err := f.compile(arg, positionAt(exp, i+2), nonTail); err != nil {
+			return err
+		}
+	}
+	if ctx.tail {
+		f.emit(OpTailApply, len(exp.Arguments)-2, exp.Pos)
+	} else {
+		f.emit(OpApply, len(exp.Arguments)-2, exp.Pos)
+	}
+	return nil
+}
+
+func compileDef(f *function, exp *common.EXP, _ context) error {
+	if f.local() {
+		return errLocalDef
+	}
+	if len(exp.Arguments) != 2 {
+		return f.fallback(exp)
+	}
+	name, ok := exp.Arguments[0].(common.REF)
+	if !ok {
+		return f.fallback(exp)
+	}
+	if err := f.compile(exp.Arguments[1], positionAt(exp, 2), context{name: string(name)}); err != nil {
+		return err
+	}
+	f.emit(OpDef, f.name(name), exp.Pos)
+	return nil
+}
+
+func compileDo(f *function, exp *common.EXP, ctx context) error {
+	if len(exp.Arguments) == 0 {
+		f.emit(OpNil, 0, exp.Pos)
+		return nil
+	}
+	last := len(exp.Arguments) - 1
+	for i, arg := range exp.Arguments[:last] {
+		if err := f.compile(arg, positionAt(exp, i+1), nonTail); err != nil {
+			return err
+		}
+		f.emit(OpPop, 0, exp.Pos)
+	}
+	return f.compile(exp.Arguments[last], positionAt(exp, last+1), ctx)
+}
+
+// compileQuote compiles quoted code to the data it quotes, which is constant
+func compileQuote(f *function, exp *common.EXP, _ context) error {
+	if len(exp.Arguments) != 1 {
+		return f.fallback(exp)
+	}
+	f.emit(OpConst, f.constant(common.Quote(exp.Arguments[0])), exp.Pos)
+	return nil
+}
+
+func compileFn(f *function, exp *common.EXP, ctx context) error {
+	if len(exp.Arguments) != 2 || !isBody(exp.Arguments[1]) {
+		return f.fallback(exp)
+	}
+	vec, ok := exp.Arguments[0].(common.VEC)
+	if !ok {
+		return f.fallback(exp)
+	}
+	params, variadic, err := common.Parameters(vec)
+	if err != nil {
+		return f.fallback(exp)
+	}
+	name := ctx.name
+	if name == "" {
+		name = "fn"
+	}
+	child := newFunction(f, name, f.scope)
+	child.fn = true
+	child.proto.Arity = len(params)
+	if variadic {
+		child.proto.Arity--
+		child.proto.Variadic = true
+	}
+	for _, param := range params {
+		name, ok := param.(common.REF)
+		if !ok {
+			return f.fallback(exp)
+		}
+		child.declare(name, child.bind())
+	}
+	if err := child.compile(exp.Arguments[1], positionAt(exp, 2), context{tail: true}); err != nil {
+		return f.fallback(exp)
+	}
+	child.emit(OpReturn, 0, exp.Pos)
+	f.overflow = f.overflow || child.overflow
+	f.proto.Protos = append(f.proto.Protos, child.proto)
+	f.emit(OpClosure, len(f.proto.Protos)-1, exp.Pos)
+	return nil
+}
+
+func compileIf(f *function, exp *common.EXP, ctx context) error {
+	if len(exp.Arguments) != 3 {
+		return f.fallback(exp)
+	}
+	if err := f.compile(exp.Arguments[0], positionAt(exp, 1), nonTail); err != nil {
+		return err
+	}
+	otherwise := f.emit(OpJumpIfFalse, 0, exp.Pos)
+	if err := f.compile(exp.Arguments[1], positionAt(exp, 2), ctx); err != nil {
+		return err
+	}
+	end := f.emit(OpJump, 0, exp.Pos)
+	f.patch(otherwise)
+	if err := f.compile(exp.Arguments[2], positionAt(exp, 3), ctx); err != nil {
+		return err
+	}
+	f.patch(end)
+	return nil
+}
+
+func compileLazyPair(f *function, exp *common.EXP, _ context) error {
+	if len(exp.Arguments) == 0 || len(exp.Arguments) > 2 {
+		return f.fallback(exp)
+	}
+	if len(exp.Arguments) == 2 && !isBody(exp.Arguments[1]) {
+		return f.fallback(exp)
+	}
+	if err := f.compile(exp.Arguments[0], positionAt(exp, 1), nonTail); err != nil {
+		return err
+	}
+	if len(exp.Arguments) == 1 {
+		f.emit(OpLazyPair, 0, exp.Pos)
+		return nil
+	}
+	child := newFunction(f, "lazypair", f.scope)
+	if err := child.compileBody(exp.Arguments[1], positionAt(exp, 2), context{tail: true}); err != nil {
+		return err
+	}
+	f.overflow = f.overflow || child.overflow
+	f.proto.Protos = append(f.proto.Protos, child.proto)
+	f.emit(OpLazyPair, len(f.proto.Protos), exp.Pos)
+	return nil
+}
+
+func compileLet(f *function, exp *common.EXP, ctx context) error {
+	bindings, ok := letBindings(exp)
+	if !ok {
+		return f.fallback(exp)
+	}
+	ip := len(f.proto.Code)
+	mark := len(f.bindings)
+	err := f.compileBindings(exp, bindings)
+	if err == nil {
+		err = f.compile(exp.Arguments[1], positionAt(exp, 2), ctx)
+	}
+	f.release(mark)
+	if err == errLocalDef {
+		f.truncate(ip)
+		return f.fallback(exp)
+	}
+	return err
+}
+
+// compileLoop binds the initial values to slots that recur assigns to before jumping back to the start of the body
+func compileLoop(f *function, exp *common.EXP, ctx context) error {
+	bindings, ok := letBindings(exp)
+	if !ok {
+		return f.fallback(exp)
+	}
+	ip := len(f.proto.Code)
+	mark := len(f.bindings)
+	if err := f.compileBindings(exp, bindings); err != nil {
+		return err
+	}
+	target := &loopTarget{start: len(f.proto.Code)}
+	for _, b := range f.bindings[mark:] {
+		target.slots = append(target.slots, b.slot)
+	}
+	f.loops++
+	err := f.compile(exp.Arguments[1], positionAt(exp, 2), context{tail: ctx.tail, loop: target})
+	f.loops--
+	f.release(mark)
+	if err != nil {
+		f.truncate(ip)
+		return f.fallback(exp)
+	}
+	return nil
+}
+
+// compileRecur assigns the new values to the slots of the enclosing loop and jumps back to its start
+func compileRecur(f *function, exp *common.EXP, ctx context) error {
+	if ctx.loop == nil || len(ctx.loop.slots) != len(exp.Arguments) {
+		return errRecur
+	}
+	for i, arg := range exp.Arguments {
+		if err := f.compile(arg, positionAt(exp, i+1), nonTail); err != nil {
+			return err
+		}
+	}
+	for i := len(ctx.loop.slots) - 1; i >= 0; i-- {
+		f.emit(OpSetLocal, ctx.loop.slots[i], exp.Pos)
+	}
+	f.emit(OpJump, ctx.loop.start, exp.Pos)
+	return nil
+}
+
+// compileBindings evaluates each value in turn, binding it before the next is evaluated
+func (f *function) compileBindings(exp *common.EXP, bindings common.VEC) error {
+	for i := 0; i < len(bindings.Vector); i += 2 {
+		pos := exp.Pos
+		if i+1 < len(bindings.Positions) {
+			pos = bindings.Positions[i+1]
+		}
+		if err := f.compile(bindings.Vector[i+1], pos, nonTail); err != nil {
+			return err
+		}
+		slot := f.bind()
+		f.emit(OpSetLocal, slot, pos)
+		f.declare(bindings.Vector[i].(common.REF), slot)
+	}
+	return nil
+}
+
+// letBindings returns the bindings of a let or loop if they are pairs of REFs and values followed by a body
+func letBindings(exp *common.EXP) (common.VEC, bool) {
+	if len(exp.Arguments) != 2 || !isBody(exp.Arguments[1]) {
+		return common.VEC{}, false
+	}
+	bindings, ok := exp.Arguments[0].(common.VEC)
+	if !ok || len(bindings.Vector)%2 > 0 {
+		return common.VEC{}, false
+	}
+	for i := 0; i < len(bindings.Vector); i += 2 {
+		if _, ok := bindings.Vector[i].(common.REF); !ok {
+			return common.VEC{}, false
+		}
+	}
+	return bindings, true
+}
+
+// isBody returns true if code can be the body of a fn, let or loop, which must be Evaluatable
+func isBody(code interfaces.Type) bool {
+	switch code.(type) {
+	case common.REF, *common.EXP:
+		return true
+	}
+	return false
+}
@@ -48,9 +48,9 @@ type Comparable interface {
 	CompareTo(Comparable) (int, error)
 }
 
-// Expandable interfaces are types that will be expanded prior to evaluation
+// Expandable interfaces are types that will be expanded into code prior to evaluation
 type Expandable interface {
-	Expand([]Type) Evaluatable
+	Expand([]Type, Scope) (Type, error)
 }
 
 // Appliable interfaces can be applied by expressions to return Values
@@ -71,10 +71,10 @@ type Scope interface {
 
 // Numeric interfaces can be combined using mathematical operations
 type Numeric interface {
-	Add(Numeric) Numeric
-	Subtract(Numeric) Numeric
-	Multiply(Numeric) Numeric
-	Divide(Numeric) Numeric
+	Add(Numeric) (Numeric, error)
+	Subtract(Numeric) (Numeric, error)
+	Multiply(Numeric) (Numeric, error)
+	Divide(Numeric) (Numeric, error)
 	IsType()
 	String() string
 	IsValue()
@@ -0,0 +1,250 @@
+// Package interpreter provides Glipso interpreters that can be embedded, side by side, within Go programs
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/mikeyhu/glipso/parser"
+	"github.com/mikeyhu/glipso/prelude"
+	"github.com/mikeyhu/glipso/vm"
+	"os"
+)
+
+// Interpreter evaluates code against a global Environment of its own, so that what one Interpreter defines is not
+// visible to any other. It may be used from any number of goroutines at once.
+type Interpreter struct {
+	env *common.Environment
+}
+
+// Option configures an Interpreter created by New
+type Option func(*Interpreter)
+
+// WithDebug enables the display of debug information while evaluating
+func WithDebug(debug bool) Option {
+	return func(i *Interpreter) {
+		i.env.SetDebug(debug)
+	}
+}
+
+// WithLimits sets quotas on each evaluation, exceeding one returns an error wrapping a common.QuotaError
+func WithLimits(limits common.Limits) Option {
+	return func(i *Interpreter) {
+		i.env.SetLimits(limits)
+	}
+}
+
+// New creates an Interpreter with the prelude loaded, configured by opts
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{env: common.NewEnvironment()}
+	prelude.ParsePrelude(i.env)
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// FuncOption configures a function registered by RegisterFunc or RegisterLazyFunc
+type FuncOption func(*funcOptions)
+
+type funcOptions struct {
+	arity   int
+	checked bool
+}
+
+// Arity makes a registered function fail unless it is applied to exactly n arguments
+func Arity(n int) FuncOption {
+	return func(o *funcOptions) {
+		o.arity = n
+		o.checked = true
+	}
+}
+
+func (o funcOptions) check(name string, n int) error {
+	if o.checked && n != o.arity {
+		return fmt.Errorf("%v : invalid number of arguments [%d of %d]", name, n, o.arity)
+	}
+	return nil
+}
+
+func newFuncOptions(opts []FuncOption) funcOptions {
+	o := funcOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// RegisterFunc makes fn available to code evaluated by the Interpreter as name. fn receives its arguments once they
+// have been evaluated. Any function, inbuilt or registered, of the same name is hidden.
+func (i *Interpreter) RegisterFunc(name string, fn func(args []interfaces.Value) (interfaces.Value, error), opts ...FuncOption) {
+	o := newFuncOptions(opts)
+	i.env.AddInbuilt(common.NewFI(name, func(args []interfaces.Value, _ interfaces.Scope) (interfaces.Value, error) {
+		if err := o.check(name, len(args)); err != nil {
+			return common.NILL, err
+		}
+		return fn(args)
+	}))
+}
+
+// RegisterLazyFunc makes fn available to code evaluated by the Interpreter as name. fn receives its arguments
+// unevaluated, along with the Scope to evaluate them in. Any function, inbuilt or registered, of the same name is
+// hidden.
+func (i *Interpreter) RegisterLazyFunc(name string, fn func(args []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error), opts ...FuncOption) {
+	o := newFuncOptions(opts)
+	i.env.AddInbuilt(common.NewLazyFI(name, func(args []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+		if err := o.check(name, len(args)); err != nil {
+			return common.NILL, err
+		}
+		return fn(args, sco)
+	}))
+}
+
+// Bind makes value, which may be any Go function or value, available to code evaluated by the Interpreter as name.
+// Arguments and results of functions are converted to and from the types of their signatures, see
+// common.Environment.Bind for the conversions made.
+func (i *Interpreter) Bind(name string, value any) error {
+	return i.env.Bind(name, value)
+}
+
+// Eval parses and evaluates the forms in src, returning the result of the last
+func (i *Interpreter) Eval(src string) (interfaces.Value, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext parses and evaluates src, stopping once ctx is cancelled or its deadline passes. The error returned is
+// then an EvalError wrapping context.Canceled or context.DeadlineExceeded. Calls running at the same time each keep
+// their own ctx, including within functions that one defined and another calls.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (interfaces.Value, error) {
+	forms, positions, err := parser.ParsePositions(src)
+	if err != nil {
+		return common.NILL, err
+	}
+	return i.evaluate(ctx, forms, positions)
+}
+
+// EvalFile parses and evaluates the forms in file, reporting positions within it by its name
+func (i *Interpreter) EvalFile(file *os.File) (interfaces.Value, error) {
+	forms, positions, err := parser.ParseFilePositions(file)
+	if err != nil {
+		return common.NILL, err
+	}
+	return i.evaluate(context.Background(), forms, positions)
+}
+
+// evaluate evaluates each of the forms in turn, returning the result of the last. Each is evaluated once those before
+// it have been, so that it can use the macros they define. An error with no position of its own, such as from a bare
+// REF, is reported at the position of the form.
+func (i *Interpreter) evaluate(ctx context.Context, forms []interfaces.Type, positions []common.Pos) (interfaces.Value, error) {
+	scope, release := common.WithContext(ctx, i.env)
+	defer release()
+	var result interfaces.Value = common.NILL
+	for f, form := range forms {
+		var err error
+		if result, err = evaluateForm(form, scope); err != nil {
+			var evalErr *common.EvalError
+			if !errors.As(err, &evalErr) {
+				evalErr = &common.EvalError{Err: err}
+				err = evalErr
+			}
+			if !evalErr.Pos.IsValid() {
+				evalErr.Pos = positions[f]
+			}
+			return common.NILL, err
+		}
+	}
+	return result, nil
+}
+
+// evaluateForm compiles form and runs it with the vm should it create a function or loop, whose code may run many
+// times. Any other form runs once, which walking it does faster than compiling it first.
+func evaluateForm(form interfaces.Type, scope interfaces.Scope) (interfaces.Value, error) {
+	if repeats(form) {
+		return vm.Evaluate(form, scope)
+	}
+	return common.Evaluate(form, scope)
+}
+
+// repeats returns true if code, other than quoted code or the body of a macro, creates a function or loop
+func repeats(code interfaces.Type) bool {
+	exp, ok := code.(*common.EXP)
+	if !ok {
+		return false
+	}
+	switch exp.Function {
+	case common.REF("fn"), common.REF("defn"), common.REF("letfn"), common.REF("loop"):
+		return true
+	case common.REF("quote"), common.REF("syntax-quote"), common.REF("macro"):
+		return false
+	}
+	if repeats(exp.Function) {
+		return true
+	}
+	for _, arg := range exp.Arguments {
+		if repeats(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand parses the forms in src and returns them with every macro they call expanded, see ExpandFile
+func (i *Interpreter) Expand(src string) ([]interfaces.Type, error) {
+	forms, err := parser.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return i.expand(forms)
+}
+
+// ExpandFile parses the forms in file and returns them with every macro they call expanded. Nothing is evaluated except
+// the macros that forms def, which are created in a scope discarded afterwards so that the forms after them can use them.
+func (i *Interpreter) ExpandFile(file *os.File) ([]interfaces.Type, error) {
+	forms, err := parser.ParseFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return i.expand(forms)
+}
+
+func (i *Interpreter) expand(forms []interfaces.Type) ([]interfaces.Type, error) {
+	scope, release := common.WithContext(context.Background(), i.env)
+	defer release()
+	sco := scope.NewChildScope()
+	expanded := make([]interfaces.Type, len(forms))
+	for f, form := range forms {
+		var err error
+		if expanded[f], err = common.MacroExpandAll(form, sco); err != nil {
+			return nil, err
+		}
+		if name, macro, ok := macroDefinition(expanded[f]); ok {
+			value, err := common.Evaluate(macro, sco)
+			if err != nil {
+				return nil, err
+			}
+			sco.CreateRef(name, value)
+		}
+	}
+	return expanded, nil
+}
+
+// macroDefinition returns the name and macro of a form that defs a macro, such as (def unless (macro [c a b] ...))
+func macroDefinition(form interfaces.Type) (common.REF, interfaces.Type, bool) {
+	exp, ok := form.(*common.EXP)
+	if !ok || exp.Function != common.REF("def") || len(exp.Arguments) != 2 {
+		return "", nil, false
+	}
+	name, ok := exp.Arguments[0].(common.REF)
+	macro, isEXP := exp.Arguments[1].(*common.EXP)
+	if !ok || !isEXP || macro.Function != common.REF("macro") {
+		return "", nil, false
+	}
+	return name, macro, true
+}
+
+// Environment returns the global Environment of the Interpreter
+func (i *Interpreter) Environment() *common.Environment {
+	return i.env
+}
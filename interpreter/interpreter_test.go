@@ -0,0 +1,701 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/mikeyhu/glipso/parser"
+	"github.com/mikeyhu/glipso/prelude"
+	"github.com/mikeyhu/glipso/vm"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Interpreter_EvalReturnsResult(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	result, err := interp.Eval("(last (range 1 (+ 2 3)))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(5), result)
+}
+
+func Test_Interpreter_EvalReturnsParseErrors(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	_, err := interp.Eval("(+ 1 2")
+
+	//then
+	assert.Error(t, err)
+}
+
+func Test_Interpreter_DefinitionsAreNotShared(t *testing.T) {
+	//given
+	first := New()
+	second := New()
+
+	//when
+	_, err := first.Eval("(def shared 1)")
+	assert.NoError(t, err)
+	_, secondErr := second.Eval("(do shared)")
+	firstResult, firstErr := first.Eval("(do shared)")
+
+	//then
+	assert.NoError(t, firstErr)
+	assert.Equal(t, common.I(1), firstResult)
+	assert.EqualError(t, secondErr, "1:5: unable to resolve REF('shared')")
+	_, ok := common.GlobalEnvironment.ResolveRef(common.REF("shared"))
+	assert.False(t, ok)
+}
+
+func Test_Interpreter_DefWithinFunctionIsLocal(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	result, err := interp.Eval("(do (defn setter [v] (let [w v] (do (def setvalue w) (+ setvalue 1)))) (setter 3))")
+	assert.NoError(t, err)
+	_, unresolved := interp.Eval("(do setvalue)")
+
+	//then
+	assert.Equal(t, common.I(4), result)
+	assert.EqualError(t, unresolved, "1:5: unable to resolve REF('setvalue')")
+}
+
+func Test_Interpreter_CountsItsOwnScopes(t *testing.T) {
+	//given
+	first := New()
+	second := New()
+	before := second.Environment().ScopesCreated()
+
+	//when
+	_, err := first.Eval("(let [a 1] (let [b 2] (+ a b)))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, before, second.Environment().ScopesCreated())
+}
+
+func Test_Interpreter_EvaluatesSideBySide(t *testing.T) {
+	//given
+	results := make(chan interfaces.Value, 4)
+
+	//when
+	for n := 0; n < 4; n++ {
+		go func(n int) {
+			interp := New()
+			_, err := interp.Eval(fmt.Sprintf("(defn addn [a] (+ a %d))", n))
+			assert.NoError(t, err)
+			result, err := interp.Eval("(apply + (map addn (range 1 10)))")
+			assert.NoError(t, err)
+			results <- result
+		}(n)
+	}
+
+	//then
+	var total common.I
+	for n := 0; n < 4; n++ {
+		total += (<-results).(common.I)
+	}
+	assert.Equal(t, common.I(55*4+10*(0+1+2+3)), total)
+}
+
+func Test_Interpreter_RegisterFuncIsApplied(t *testing.T) {
+	//given
+	interp := New()
+	interp.RegisterFunc("double", func(args []interfaces.Value) (interfaces.Value, error) {
+		return args[0].(common.I) * 2, nil
+	})
+
+	//when
+	result, err := interp.Eval("(apply + (map double (range 1 3)))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(12), result)
+}
+
+func Test_Interpreter_RegisterFuncChecksArity(t *testing.T) {
+	//given
+	interp := New()
+	interp.RegisterFunc("now", func(args []interfaces.Value) (interfaces.Value, error) {
+		return common.I(1), nil
+	}, Arity(0))
+
+	//when
+	_, err := interp.Eval("(now 1)")
+
+	//then
+	assert.EqualError(t, err, "1:1: now : invalid number of arguments [1 of 0]")
+}
+
+func Test_Interpreter_RegisterFuncReturnsErrors(t *testing.T) {
+	//given
+	interp := New()
+	interp.RegisterFunc("lookup", func(args []interfaces.Value) (interfaces.Value, error) {
+		return common.NILL, fmt.Errorf("lookup : %v not found", args[0])
+	})
+
+	//when
+	_, err := interp.Eval("(do\n  (lookup \"user\"))")
+
+	//then
+	assert.EqualError(t, err, "2:3: lookup : user not found")
+}
+
+func Test_Interpreter_RegisterFuncIsNotShared(t *testing.T) {
+	//given
+	first := New()
+	second := New()
+	first.RegisterFunc("+", func(args []interfaces.Value) (interfaces.Value, error) {
+		return common.S("replaced"), nil
+	})
+
+	//when
+	firstResult, firstErr := first.Eval("(+ 1 2)")
+	secondResult, secondErr := second.Eval("(+ 1 2)")
+
+	//then
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, common.S("replaced"), firstResult)
+	assert.Equal(t, common.I(3), secondResult)
+}
+
+func Test_Interpreter_RegisterLazyFuncReceivesUnevaluatedArguments(t *testing.T) {
+	//given
+	interp := New()
+	interp.RegisterLazyFunc("unless", func(args []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+		test, err := common.Evaluate(args[0], sco)
+		if err != nil {
+			return common.NILL, err
+		}
+		if test == common.B(false) {
+			return common.Evaluate(args[1], sco)
+		}
+		return common.NILL, nil
+	}, Arity(2))
+
+	//when
+	result, err := interp.Eval("(let [a 1] (unless (= a 2) (+ a 10)))")
+	_, arityErr := interp.Eval("(unless true)")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(11), result)
+	assert.EqualError(t, arityErr, "1:1: unless : invalid number of arguments [1 of 2]")
+}
+
+func Test_Interpreter_RegisterLazyFuncCanReplaceSpecialForm(t *testing.T) {
+	//given
+	interp := New()
+	interp.RegisterLazyFunc("if", func(args []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+		return common.S("replaced"), nil
+	})
+
+	//when
+	result, err := interp.Eval("(if true 1 2)")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.S("replaced"), result)
+}
+
+func Test_Interpreter_BindConvertsArgumentsAndResults(t *testing.T) {
+	//given
+	interp := New()
+	err := interp.Bind("scale", func(n int, unit string) (float64, error) {
+		if unit != "half" {
+			return 0, fmt.Errorf("unknown unit %v", unit)
+		}
+		return float64(n) / 2, nil
+	})
+	assert.NoError(t, err)
+
+	//when
+	result, err := interp.Eval("(scale 3 \"half\")")
+	_, unitErr := interp.Eval("(scale 3 \"third\")")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.F(1.5), result)
+	assert.EqualError(t, unitErr, "1:1: scale : unknown unit third")
+}
+
+func Test_Interpreter_BindReportsArgumentPosition(t *testing.T) {
+	//given
+	interp := New()
+	err := interp.Bind("repeatstring", func(s string, n int) string { return s })
+	assert.NoError(t, err)
+
+	//when
+	_, err = interp.Eval("(repeatstring \"a\" \"b\")")
+
+	//then
+	assert.EqualError(t, err, "1:1: repeatstring : argument 2 expected int, recieved b")
+}
+
+func Test_Interpreter_BindConvertsListsAndMaps(t *testing.T) {
+	//given
+	interp := New()
+	assert.NoError(t, interp.Bind("total", func(prices map[string]float64, items ...[]string) float64 {
+		total := 0.0
+		for _, list := range items {
+			for _, item := range list {
+				total += prices[item]
+			}
+		}
+		return total
+	}))
+	assert.NoError(t, interp.Bind("evens", func(upto int) []int {
+		var evens []int
+		for n := 2; n <= upto; n += 2 {
+			evens = append(evens, n)
+		}
+		return evens
+	}))
+
+	//when
+	total, err := interp.Eval("(total (hash-map :tea 1.5 :cake 2) [\"tea\" \"cake\"] (cons \"tea\"))")
+	evens, evensErr := interp.Eval("(apply + (evens 10))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.F(5), total)
+	assert.NoError(t, evensErr)
+	assert.Equal(t, common.I(30), evens)
+}
+
+func Test_Interpreter_ATOMIsSharedBetweenInterpreters(t *testing.T) {
+	//given
+	hits := common.NewATOM(common.I(0))
+	done := make(chan bool, 4)
+
+	//when
+	for n := 0; n < 4; n++ {
+		go func() {
+			interp := New()
+			assert.NoError(t, interp.Bind("hits", hits))
+			_, err := interp.Eval("(loop [i 0] (if (< i 50) (do (swap! hits + 1) (recur (+ i 1))) @hits))")
+			assert.NoError(t, err)
+			done <- true
+		}()
+	}
+	for n := 0; n < 4; n++ {
+		<-done
+	}
+
+	//then
+	assert.Equal(t, common.I(200), hits.Deref())
+}
+
+func Test_Interpreter_DefWhileOtherGoroutinesEvaluate(t *testing.T) {
+	//given
+	interp := New()
+	_, err := interp.Eval("(def one 1) (def addone (eval '(fn [n] (+ n one))))")
+	assert.NoError(t, err)
+	defs := strings.Builder{}
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&defs, "(def defined%d %d)", i, i)
+	}
+	var wg sync.WaitGroup
+
+	//when
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := interp.Eval("(do " + defs.String() + ")")
+		assert.NoError(t, err)
+	}()
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := interp.Eval("(loop [i 0] (if (< i 1000) (recur (addone i)) i))")
+			assert.NoError(t, err)
+			assert.Equal(t, common.I(1000), result)
+		}()
+	}
+	wg.Wait()
+
+	//then
+	result, err := interp.Eval("(+ defined999 (addone 1))")
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(1001), result)
+}
+
+func Test_Interpreter_SwapBoundAtomFromManyGoroutines(t *testing.T) {
+	//given
+	interp := New()
+	counter := common.NewATOM(common.I(0))
+	assert.NoError(t, interp.Bind("counter", counter))
+	_, err := interp.Eval(`
+		(def changes (atom 0))
+		(add-watch counter :changes (fn [k a old new] (swap! changes + 1)))
+		(defn hit [n] (swap! counter + n))
+		(defn hit-and-remember [n] (do (def last-hit n) (swap! counter + n)))
+		(def ones (map (fn [x] (- x x -1)) (range 1 10)))`)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+
+	//when
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				code := "(hit (first (tail ones)))"
+				if n%2 == 1 {
+					code = fmt.Sprintf("(do (add-watch counter :watch%d (fn [k a old new] @a)) (hit-and-remember 1))", n)
+				}
+				_, err := interp.Eval(code)
+				assert.NoError(t, err)
+				counter.Deref()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	//then
+	changes, err := interp.Eval("@changes")
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(800), counter.Deref())
+	assert.Equal(t, common.I(800), changes)
+}
+
+func Test_Interpreter_BindValue(t *testing.T) {
+	//given
+	interp := New()
+	assert.NoError(t, interp.Bind("config", map[string]any{"retries": 3, "hosts": []string{"a", "b"}}))
+
+	//when
+	retries, err := interp.Eval("(+ (:retries config) 1)")
+	host, hostErr := interp.Eval("(last (:hosts config))")
+	bindErr := interp.Bind("channel", make(chan int))
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(4), retries)
+	assert.NoError(t, hostErr)
+	assert.Equal(t, common.S("b"), host)
+	assert.EqualError(t, bindErr, "channel : unable to convert chan int")
+}
+
+func Test_Interpreter_ToGoGuardsAgainstInfiniteLists(t *testing.T) {
+	//given
+	interp := New()
+	naturals, err := interp.Eval("(do (defn from [n] (lazypair n (from (+ n 1)))) (from 1))")
+	assert.NoError(t, err)
+	first, err := interp.Eval("(take 3 (from 1))")
+	assert.NoError(t, err)
+
+	//when
+	_, infiniteErr := common.ToGoLimit(naturals, interp.Environment(), 1000)
+	result, firstErr := common.ToGo(first, interp.Environment())
+
+	//then
+	assert.EqualError(t, infiniteErr, "list has more than 1000 items")
+	assert.NoError(t, firstErr)
+	assert.Equal(t, []any{1, 2, 3}, result)
+}
+
+func Test_Interpreter_EvalContextEnforcesDeadline(t *testing.T) {
+	//given
+	interp := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	//when
+	_, err := interp.EvalContext(ctx, "(apply + (range 1 1000000000))")
+	result, afterErr := interp.Eval("(+ 1 2)")
+
+	//then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, afterErr)
+	assert.Equal(t, common.I(3), result)
+}
+
+func Test_Interpreter_OverlappingEvalContextsKeepTheirOwnDeadlines(t *testing.T) {
+	//given
+	interp := New()
+	var stop atomic.Bool
+	started := make(chan struct{})
+	assert.NoError(t, interp.Bind("started", func() { close(started) }))
+	assert.NoError(t, interp.Bind("stopped", stop.Load))
+	_, err := interp.Eval("(defn spin [] (do (def spinning true) (loop [i 0] (recur (+ i 1)))))")
+	assert.NoError(t, err)
+	short, cancelShort := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelShort()
+	long, cancelLong := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelLong()
+	shortErr, longErr := make(chan error, 1), make(chan error, 1)
+
+	//when
+	go func() {
+		_, err := interp.EvalContext(short, "(do (started) (spin))")
+		shortErr <- err
+	}()
+	<-started
+	go func() {
+		_, err := interp.EvalContext(long, "(loop [i 0] (if (stopped) i (recur (+ i 1))))")
+		longErr <- err
+	}()
+
+	//then
+	select {
+	case err := <-shortErr:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(10 * time.Second):
+		t.Error("expected the evaluation with the shorter deadline to have stopped")
+	}
+	stop.Store(true)
+	assert.NoError(t, <-longErr)
+}
+
+func Test_Interpreter_WithLimitsReportsQuotaErrors(t *testing.T) {
+	tests := []struct {
+		limits common.Limits
+		code   string
+		quota  common.Quota
+	}{
+		{common.Limits{Steps: 1000}, "(loop [i 0] (recur (+ i 1)))", common.StepsQuota},
+		{common.Limits{Steps: 1000}, "(apply + (range 1 100000))", common.StepsQuota},
+		{common.Limits{Steps: 1000}, "(first (filter (fn [x] (< x 0)) (range 1 100000)))", common.StepsQuota},
+		{common.Limits{Depth: 50}, "(do (defn sum [n] (if (= n 0) 0 (+ n (sum (- n 1))))) (sum 100))", common.DepthQuota},
+		{common.Limits{Cells: 1000}, "(apply + (range 1 100000))", common.CellsQuota},
+		{common.Limits{Scopes: 100}, "(apply + (map (fn [x] (let [y x] y)) (range 1 1000)))", common.ScopesQuota},
+	}
+	for _, test := range tests {
+		//given
+		interp := New(WithLimits(test.limits))
+
+		//when
+		_, err := interp.Eval(test.code)
+
+		//then
+		var quotaErr *common.QuotaError
+		if assert.ErrorAs(t, err, &quotaErr, test.code) {
+			assert.Equal(t, test.quota, quotaErr.Quota)
+		}
+	}
+}
+
+func Test_Interpreter_WithLimitsAppliesToEachEvaluation(t *testing.T) {
+	//given
+	interp := New(WithLimits(common.Limits{Steps: 100, Depth: 10}))
+
+	//when
+	var err error
+	for n := 0; n < 10 && err == nil; n++ {
+		_, err = interp.Eval("(do (defn sum [n] (if (= n 0) 0 (+ n (sum (- n 1))))) (sum 5))")
+	}
+
+	//then
+	assert.NoError(t, err)
+}
+
+func Test_Interpreter_EvalEvaluatesEachForm(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	result, err := interp.Eval("(defmacro unless [c a b] `(if ~c ~b ~a))\n(def a 1)\n(unless (= a 1) 2 a)")
+	empty, emptyErr := interp.Eval("; nothing")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(1), result)
+	assert.NoError(t, emptyErr)
+	assert.Equal(t, common.NILL, empty)
+}
+
+func Test_Interpreter_CompilesFormsCreatingFunctions(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	result, err := interp.Eval("(do (defn add-one [x] (+ x 1)) (def adder (fn [x] (+ x 2))))\n(def two (add-one 1))\n(adder two)")
+	addOne, _ := interp.Environment().ResolveRef(common.REF("add-one"))
+	adder, _ := interp.Environment().ResolveRef(common.REF("adder"))
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(4), result)
+	assert.IsType(t, &vm.Closure{}, addOne)
+	assert.IsType(t, &vm.Closure{}, adder)
+}
+
+func Test_Interpreter_ExpandDefinesMacrosWithoutEvaluatingOtherForms(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	forms, err := interp.Expand("(defmacro unless [c a b] `(if ~c ~b ~a))\n(def b (do (def a 1) 2))\n(unless false (def c 1) 2)")
+	_, foundA := interp.Environment().ResolveRef(common.REF("a"))
+	_, foundB := interp.Environment().ResolveRef(common.REF("b"))
+	_, foundC := interp.Environment().ResolveRef(common.REF("c"))
+	_, foundUnless := interp.Environment().ResolveRef(common.REF("unless"))
+
+	//then
+	assert.NoError(t, err)
+	assert.Len(t, forms, 3)
+	assert.Equal(t, "(if false 2 (def c 1))", common.Sprint(forms[2], interp.Environment()))
+	assert.False(t, foundA)
+	assert.False(t, foundB)
+	assert.False(t, foundC)
+	assert.False(t, foundUnless)
+}
+
+func Test_Interpreter_ReportsPositionOfTopLevelREF(t *testing.T) {
+	//given
+	interp := New()
+
+	//when
+	_, err := interp.Eval("(def a 1)\n  missing")
+	_, macroErr := interp.Eval("(defmacro missing-ref [] 'missing)\n(+ 1\n  (missing-ref))")
+
+	//then
+	assert.EqualError(t, err, "2:3: unable to resolve REF('missing')")
+	assert.EqualError(t, macroErr, "3:3: unable to resolve REF('missing')")
+}
+
+func Test_Interpreter_OverlappingEvaluationsHaveTheirOwnQuotas(t *testing.T) {
+	//given
+	interp := New(WithLimits(common.Limits{Steps: 10000}))
+	var started, counted sync.WaitGroup
+	started.Add(2)
+	counted.Add(2)
+	meet := func(group *sync.WaitGroup) func() {
+		return func() {
+			group.Done()
+			met := make(chan struct{})
+			go func() {
+				group.Wait()
+				close(met)
+			}()
+			select {
+			case <-met:
+			case <-time.After(10 * time.Second):
+			}
+		}
+	}
+	assert.NoError(t, interp.Bind("started", meet(&started)))
+	assert.NoError(t, interp.Bind("counted", meet(&counted)))
+	_, err := interp.Eval("(defn count-to [n] (do (def counting true) (loop [i 0] (if (= i n) i (recur (+ i 1))))))")
+	assert.NoError(t, err)
+
+	//when
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := interp.Eval("(do (started) (count-to 1500) (counted))")
+			errs <- err
+		}()
+	}
+
+	//then
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+}
+
+func Test_Interpreter_RecursionWithoutLimitsReturnsDepthQuotaError(t *testing.T) {
+	tests := []string{
+		"(defn g [x] (+ 1 (g x))) (g 1)",
+		"(defn g [x] (do (def y 1) (+ 1 (g x)))) (g 1)",
+		"(defn g [x] (first (map g (cons x)))) (g 1)",
+		"(defmacro m [] (macroexpand '(m))) (m)",
+		"(defmacro mm [] (quote (mm))) (mm)",
+		"(defmacro mm [] (quote (+ 1 (mm)))) (mm)",
+		"(defmacro mm [] (quote (mm))) (loop [i 0] (mm))",
+		"(defmacro mm [] (quote (mm))) (macroexpand '(mm))",
+		"(defmacro mm [] (quote (+ 1 (mm)))) (macroexpand-all '(mm))",
+		"(defmacro mm [] (quote (+ 1 (mm)))) (defn h [] (do (def z 1) (mm))) (h)",
+	}
+	for _, code := range tests {
+		t.Run(code, func(t *testing.T) {
+			//when
+			_, err := New().Eval(code)
+
+			//then
+			var quotaErr *common.QuotaError
+			assert.ErrorAs(t, err, &quotaErr)
+			assert.Equal(t, &common.QuotaError{Quota: common.DepthQuota, Limit: common.DefaultDepth}, quotaErr)
+		})
+	}
+}
+
+func Test_Interpreter_ExpandingMacroWithoutEndReturnsDepthQuotaError(t *testing.T) {
+	//when
+	_, err := New().Expand("(defmacro mm [] (quote (+ 1 (mm)))) (mm)")
+
+	//then
+	var quotaErr *common.QuotaError
+	assert.ErrorAs(t, err, &quotaErr)
+}
+
+func Test_Interpreter_TryCannotCatchQuotaErrors(t *testing.T) {
+	//given
+	interp := New(WithLimits(common.Limits{Steps: 1000}))
+
+	//when
+	_, err := interp.Eval("(try (loop [i 0] (recur (+ i 1))) (catch (fn [e] (= (get :type (ex-data e)) :error)) e 0))")
+
+	//then
+	var quotaErr *common.QuotaError
+	assert.ErrorAs(t, err, &quotaErr)
+}
+
+// FuzzInterpreter_EvalNeverPanics evaluates any code that parses, both compiled and with the tree walking evaluator,
+// failing should it panic. It is evaluated both with Limits and without, when the default Depth and a deadline stop
+// code that would not otherwise finish.
+func FuzzInterpreter_EvalNeverPanics(f *testing.F) {
+	files, _ := filepath.Glob("../acceptance/*.glipso")
+	for _, file := range files {
+		if code, err := os.ReadFile(file); err == nil {
+			f.Add(string(code))
+		}
+	}
+	for _, code := range []string{
+		"(% 1 0)", "(range 'a 2)", "(fn x 1)", "(def 1 2)", "(< \"a\" 1)", "(panic \"stop\")",
+		"(defmacro m [a] a) (m)", "(tail (tail (cons 1)))", "(let [[a & b] (range 1 3)] (first b))",
+		"(try (+ 1 'a) (catch (fn [e] (= (get :type (ex-data e)) :error)) e (ex-message e)))",
+		"(defn g [x] (+ 1 (g x))) (g 1)", "(defn g [x] (do (def y 1) (+ 1 (g x)))) (g 1)",
+		"(defmacro mm [] (quote (mm))) (mm)", "(defmacro mm [] (quote (+ 1 (mm)))) (macroexpand-all '(mm))",
+	} {
+		f.Add(code)
+	}
+	limits := common.Limits{Steps: 10000, Depth: 100, Cells: 10000, Scopes: 1000}
+	f.Fuzz(func(t *testing.T, code string) {
+		forms, err := parser.Parse(code)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, _ = New(WithLimits(limits)).EvalContext(ctx, code)
+		_, _ = New().EvalContext(ctx, code)
+
+		env := common.NewEnvironment()
+		prelude.ParsePrelude(env)
+		env.SetLimits(limits)
+		scope, release := common.WithContext(ctx, env)
+		defer release()
+		for _, form := range forms {
+			if _, err := common.Evaluate(form, scope); err != nil {
+				return
+			}
+		}
+	})
+}
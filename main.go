@@ -4,33 +4,96 @@ import (
 	"flag"
 	"fmt"
 	"github.com/mikeyhu/glipso/common"
-	"github.com/mikeyhu/glipso/parser"
-	"github.com/mikeyhu/glipso/prelude"
+	"github.com/mikeyhu/glipso/interpreter"
+	"github.com/mikeyhu/glipso/repl"
 	"os"
+	"path/filepath"
 )
 
 func main() {
-	env := common.GlobalEnvironment
-
-	prelude.ParsePrelude(env)
-
 	debug := flag.Bool("debug", false, "Enable debug output")
+	history := flag.String("history", defaultHistory(), "File the repl reads its history from and appends to")
 	flag.Parse()
 
-	common.DEBUG = *debug
+	interp := interpreter.New(interpreter.WithDebug(*debug))
 	args := flag.Args()
 
-	var exp *common.EXP
-	if len(args) > 0 {
-		file, _ := os.Open(args[0])
-		exp, _ = parser.ParseFile(file)
-	} else {
-		exp, _ = parser.ParseFile(os.Stdin)
+	if len(args) > 0 && args[0] == "repl" {
+		runREPL(interp, *history)
+		return
+	}
+	if len(args) > 0 && args[0] == "expand" {
+		runExpand(interp, args[1:])
+		return
+	}
+	runFile(interp, args)
+}
+
+// openInput opens the file named by the first of args, or returns stdin if there are none. The file should be closed
+// once read.
+func openInput(args []string) *os.File {
+	if len(args) == 0 {
+		return os.Stdin
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		exitWithError(err)
+	}
+	return file
+}
+
+// runFile evaluates the file named by the first of args, or stdin, printing the result
+func runFile(interp *interpreter.Interpreter, args []string) {
+	file := openInput(args)
+	defer file.Close()
+	output, err := interp.EvalFile(file)
+	if err != nil {
+		exitWithError(err)
 	}
-	output, err := exp.Evaluate(env)
 	fmt.Println(output)
+	interp.Environment().DisplayDiagnostics()
+}
+
+// runExpand prints each of the forms in the file named by the first of args, or stdin, with every macro expanded
+func runExpand(interp *interpreter.Interpreter, args []string) {
+	file := openInput(args)
+	defer file.Close()
+	forms, err := interp.ExpandFile(file)
+	if err != nil {
+		exitWithError(err)
+	}
+	for _, form := range forms {
+		fmt.Println(common.Sprint(form, interp.Environment()))
+	}
+}
+
+func runREPL(interp *interpreter.Interpreter, history string) {
+	r := repl.New(interp, os.Stdin, os.Stdout)
+	if history != "" {
+		file, err := repl.OpenHistory(history)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer file.Close()
+		if err := r.WithHistory(file); err != nil {
+			exitWithError(err)
+		}
+	}
+	if err := r.Run(); err != nil {
+		exitWithError(err)
+	}
+}
+
+// defaultHistory returns the path of .glipso_history in the home directory, or no path if it is not known
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
 	if err != nil {
-		panic(err)
+		return ""
 	}
-	common.GlobalEnvironment.DisplayDiagnostics()
+	return filepath.Join(home, ".glipso_history")
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, common.ErrorDetail(err))
+	os.Exit(1)
 }
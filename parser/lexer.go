@@ -1,27 +1,56 @@
 package parser
 
 import (
+	"bytes"
 	"errors"
 	"unicode/utf8"
 )
 
 type token int
 
-var delimiters = [...]rune{'(', ')', '[', ']'}
+var delimiters = [...]rune{'(', ')', '[', ']', '{', '}'}
+
+// discard is the token that discards the form following it
+const discard = "#_"
+
+// readerMacros maps the tokens that prefix a form to the name of the form that wraps it, so that 'x reads as (quote x)
+// and @x as (deref x)
+var readerMacros = map[string]string{
+	"'":  "quote",
+	"`":  "syntax-quote",
+	"~":  "unquote",
+	"~@": "unquote-splicing",
+	"@":  "deref",
+}
 
 func tokenize(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	start := 0
-	for width := 0; start < len(data); start += width {
-		var r rune
-		r, width = utf8.DecodeRune(data[start:])
-		if !isSpace(r) {
-			break
-		}
+	start, ended := ignored(data, atEOF)
+	if !ended {
+		return 0, nil, nil
 	}
 	char, width := utf8.DecodeRune(data[start:])
 	if isDelimiter(char) {
 		return start + width, data[start : start+width], nil
 	}
+	if char == '#' {
+		if start+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if start+1 < len(data) && data[start+1] == '_' {
+			return start + 2, data[start : start+2], nil
+		}
+	}
+	if isReaderMacro(char) {
+		if char == '~' {
+			if start+1 == len(data) && !atEOF {
+				return 0, nil, nil
+			}
+			if start+1 < len(data) && data[start+1] == '@' {
+				return start + 2, data[start : start+2], nil
+			}
+		}
+		return start + width, data[start : start+width], nil
+	}
 	if isStringDelimiter(char) {
 		for width, i := 0, start+1; i < len(data); i += width {
 			var r rune
@@ -48,6 +77,39 @@ func tokenize(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	return start, nil, nil
 }
 
+// leadingSpace returns the number of bytes of whitespace and comments at the start of data
+func leadingSpace(data []byte) int {
+	start, _ := ignored(data, true)
+	return start
+}
+
+// ignored returns the number of bytes of whitespace and comments at the start of data. ended is false when a comment
+// runs to the end of data, unless atEOF, as more data is needed to find where it ends.
+func ignored(data []byte, atEOF bool) (start int, ended bool) {
+	for width := 0; start < len(data); start += width {
+		var r rune
+		r, width = utf8.DecodeRune(data[start:])
+		if isComment(r) {
+			end := bytes.IndexByte(data[start:], '\n')
+			if end < 0 {
+				return len(data), atEOF
+			}
+			width = end + 1
+		} else if !isSpace(r) {
+			break
+		}
+	}
+	return start, true
+}
+
+func isComment(r rune) bool {
+	return r == ';'
+}
+
+func isReaderMacro(r rune) bool {
+	return r == '\'' || r == '`' || r == '~' || r == '@'
+}
+
 func isStringDelimiter(r rune) bool {
 	return r == '"'
 }
@@ -77,7 +139,7 @@ func isSpace(r rune) bool {
 }
 
 func isSpaceOrDelimiter(r rune) bool {
-	return isSpace(r) || isDelimiter(r)
+	return isSpace(r) || isDelimiter(r) || isComment(r)
 }
 
 func isDelimiter(r rune) bool {
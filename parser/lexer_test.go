@@ -90,3 +90,76 @@ func Test_tokenize_QuotedStringContainingEscapedQuote(t *testing.T) {
 	assert.Equal(t, 15, advance)
 	assert.Equal(t, []byte(`"quote \" here"`), token)
 }
+
+func Test_tokenize_SkipsLineComment(t *testing.T) {
+	data := []byte("; a comment (\n  word")
+	advance, token, err := tokenize(data, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, advance)
+	assert.Equal(t, []byte("word"), token)
+}
+
+func Test_tokenize_CommentEndsWord(t *testing.T) {
+	data := []byte("word;comment\n")
+	advance, token, err := tokenize(data, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, advance)
+	assert.Equal(t, []byte("word"), token)
+}
+
+func Test_tokenize_WaitsForEndOfComment(t *testing.T) {
+	data := []byte("; a comment that continues")
+	advance, token, err := tokenize(data, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, advance)
+	assert.Nil(t, token)
+}
+
+func Test_tokenize_CommentAtEndOfReader(t *testing.T) {
+	data := []byte("; the end")
+	advance, token, err := tokenize(data, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, advance)
+	assert.Nil(t, token)
+}
+
+func Test_tokenize_SemicolonWithinString(t *testing.T) {
+	data := []byte(`"not; a comment" `)
+	advance, token, err := tokenize(data, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 16, advance)
+	assert.Equal(t, []byte(`"not; a comment"`), token)
+}
+
+func Test_tokenize_Discard(t *testing.T) {
+	data := []byte("#_(a b)")
+	advance, token, err := tokenize(data, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, advance)
+	assert.Equal(t, []byte("#_"), token)
+}
+
+func Test_tokenize_ReaderMacros(t *testing.T) {
+	for _, macro := range []string{"'", "`", "~", "~@", "@"} {
+		data := []byte(macro + "(a b)")
+		advance, token, err := tokenize(data, false)
+		assert.NoError(t, err)
+		assert.Equal(t, len(macro), advance)
+		assert.Equal(t, []byte(macro), token)
+	}
+}
+
+func Test_tokenize_UnquoteNeedsMoreData(t *testing.T) {
+	advance, token, err := tokenize([]byte("~"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, advance)
+	assert.Nil(t, token)
+}
+
+func Test_tokenize_QuoteWithinWord(t *testing.T) {
+	data := []byte("a'b ")
+	advance, token, err := tokenize(data, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, advance)
+	assert.Equal(t, []byte("a'b"), token)
+}
@@ -8,103 +8,296 @@ import (
 	"errors"
 	"github.com/mikeyhu/glipso/common"
 	"github.com/mikeyhu/glipso/interfaces"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-//Parse parses a string containing some code and returns an EXP that represents it
-func Parse(input string) (*common.EXP, error) {
-	s := bufio.NewScanner(strings.NewReader(input))
-	s.Split(tokenize)
-	return root(s)
+//Parse parses a string containing some code and returns each of the forms within it, in order
+func Parse(input string) ([]interfaces.Type, error) {
+	forms, _, err := root(newScanner("", input))
+	return forms, err
 }
 
-//ParseFile parses code from the provided file and returns an EXP that represents it
-func ParseFile(inputFile *os.File) (*common.EXP, error) {
-	s := bufio.NewScanner(inputFile)
-	s.Split(tokenize)
-	return root(s)
+//ParseFile parses code from the provided file and returns each of the forms within it, in order
+func ParseFile(inputFile *os.File) ([]interfaces.Type, error) {
+	forms, _, err := ParseFilePositions(inputFile)
+	return forms, err
 }
 
-func root(s *bufio.Scanner) (*common.EXP, error) {
-	more := s.Scan()
-	text := s.Text()
-	if !more {
-		return nil, errors.New("Unexpected EOF")
+// ParsePositions parses code as Parse does, also returning the position of each form. A form that is not an EXP or
+// VEC, such as a bare REF, has no position of its own, so the position returned is the only record of where it is.
+func ParsePositions(input string) ([]interfaces.Type, []common.Pos, error) {
+	return root(newScanner("", input))
+}
+
+// ParseFilePositions parses code from the provided file as ParseFile does, also returning the position of each form
+func ParseFilePositions(inputFile *os.File) ([]interfaces.Type, []common.Pos, error) {
+	input, err := io.ReadAll(inputFile)
+	if err != nil {
+		return nil, nil, err
 	}
-	if text == "(" {
-		_, exp, err := parseExpression(s)
-		return exp, err
+	return root(newScanner(inputFile.Name(), string(input)))
+}
+
+// Incomplete returns true if input ends within an EXP, VEC or string that has not been closed, so that more input is
+// needed before it can be parsed
+func Incomplete(input string) bool {
+	depth := 0
+	for data := []byte(input); len(data) > 0; {
+		advance, token, err := tokenize(data, true)
+		if err != nil {
+			return true
+		}
+		if advance == 0 {
+			break
+		}
+		data = data[advance:]
+		switch string(token) {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// scanner splits code into tokens, keeping track of where in the source each token started
+type scanner struct {
+	*bufio.Scanner
+	source     *common.Source
+	input      string
+	lineStarts []int
+	offset     int
+	start      int
+}
+
+func newScanner(name string, input string) *scanner {
+	s := &scanner{
+		Scanner:    bufio.NewScanner(strings.NewReader(input)),
+		source:     common.NewSource(name, input),
+		input:      input,
+		lineStarts: []int{0},
+	}
+	for i, c := range input {
+		if c == '\n' {
+			s.lineStarts = append(s.lineStarts, i+1)
+		}
+	}
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := tokenize(data, atEOF)
+		if token != nil {
+			s.start = s.offset + advance - len(token)
+		} else if err != nil {
+			s.start = s.offset + leadingSpace(data)
+		}
+		s.offset += advance
+		return advance, token, err
+	})
+	return s
+}
+
+// pos returns the position of the most recently scanned token
+func (s *scanner) pos() common.Pos {
+	line := sort.Search(len(s.lineStarts), func(i int) bool { return s.lineStarts[i] > s.start })
+	lineStart := s.lineStarts[line-1]
+	column := utf8.RuneCountInString(s.input[lineStart:s.start]) + 1
+	return common.Pos{Source: s.source, Line: line, Column: column}
+}
+
+// errorAt creates an error that refers back to a position in the source
+func (s *scanner) errorAt(pos common.Pos, message string) error {
+	return &common.SourceError{Pos: pos, Err: errors.New(message)}
+}
+
+// next scans the next token, returning false when there are none left or the token could not be read
+func (s *scanner) next() (bool, error) {
+	if s.Scan() {
+		return true, nil
+	}
+	if err := s.Err(); err != nil {
+		return false, &common.SourceError{Pos: s.pos(), Err: err}
+	}
+	return false, nil
+}
+
+// root parses every form, and its position, failing should anything be left over that is not a form
+func root(s *scanner) ([]interfaces.Type, []common.Pos, error) {
+	forms := []interfaces.Type{}
+	positions := []common.Pos{}
+	for {
+		more, err := s.next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !more {
+			return forms, positions, nil
+		}
+		token := s.Text()
+		if isClosing(token) {
+			return nil, nil, s.errorAt(s.pos(), "unexpected "+token)
+		}
+		if s, forms, positions, err = addElementToArray(s, forms, positions, token); err != nil {
+			return nil, nil, err
+		}
 	}
-	return nil, errors.New("no EXP found")
 }
 
 //
-func parseExpression(s *bufio.Scanner) (*bufio.Scanner, *common.EXP, error) {
+func parseExpression(s *scanner, start common.Pos) (*scanner, *common.EXP, error) {
 	args := []interfaces.Type{}
+	positions := []common.Pos{}
 	more := true
 	var err error
 	for more {
-		more = s.Scan()
+		more, err = s.next()
+		if err != nil {
+			return s, nil, err
+		}
 		token := s.Text()
 		if token == ")" {
+			if len(args) == 0 {
+				return s, nil, s.errorAt(start, "EXP has no function")
+			}
 			head := args[0]
 			tail := args[1:]
-			return s, &common.EXP{Function: head, Arguments: tail}, nil
+			return s, &common.EXP{Function: head, Arguments: tail, Pos: start, Positions: positions}, nil
 		}
-		s, args, err = addElementToArray(s, args, token)
+		s, args, positions, err = addElementToArray(s, args, positions, token)
 		if err != nil {
 			return s, nil, err
 		}
 	}
-	return s, nil, errors.New("Unexpected EOF while parsing EXP")
+	return s, nil, s.errorAt(start, "Unexpected EOF while parsing EXP")
 }
 
-func parseVector(s *bufio.Scanner) (*bufio.Scanner, *common.VEC, error) {
+func parseVector(s *scanner, start common.Pos) (*scanner, *common.VEC, error) {
 	vec := []interfaces.Type{}
+	positions := []common.Pos{}
 	more := true
 	var err error
 	for more {
-		more = s.Scan()
+		more, err = s.next()
+		if err != nil {
+			return s, nil, err
+		}
 		token := s.Text()
 		if token == "]" {
-			return s, &common.VEC{vec}, nil
+			return s, &common.VEC{Vector: vec, Pos: start, Positions: positions}, nil
 		}
-		s, vec, err = addElementToArray(s, vec, token)
+		s, vec, positions, err = addElementToArray(s, vec, positions, token)
 		if err != nil {
 			return s, nil, err
 		}
 	}
-	return s, nil, errors.New("Unexpected EOF while parsing VEC")
+	return s, nil, s.errorAt(start, "Unexpected EOF while parsing VEC")
 }
 
-func addElementToArray(s *bufio.Scanner, list []interfaces.Type, token string) (*bufio.Scanner, []interfaces.Type, error) {
+// parseMap parses the forms of a map, such as {:a 1}, into the EXP (hash-map :a 1) that creates it
+func parseMap(s *scanner, start common.Pos) (*scanner, *common.EXP, error) {
+	forms := []interfaces.Type{}
+	positions := []common.Pos{start}
+	more := true
 	var err error
+	for more {
+		more, err = s.next()
+		if err != nil {
+			return s, nil, err
+		}
+		token := s.Text()
+		if token == "}" {
+			if len(forms)%2 > 0 {
+				return s, nil, s.errorAt(start, "expected an even number of forms in map")
+			}
+			return s, &common.EXP{Function: common.REF("hash-map"), Arguments: forms, Pos: start, Positions: positions}, nil
+		}
+		s, forms, positions, err = addElementToArray(s, forms, positions, token)
+		if err != nil {
+			return s, nil, err
+		}
+	}
+	return s, nil, s.errorAt(start, "Unexpected EOF while parsing map")
+}
+
+func addElementToArray(s *scanner, list []interfaces.Type, positions []common.Pos, token string) (*scanner, []interfaces.Type, []common.Pos, error) {
+	var err error
+	pos := s.pos()
+	if token == discard {
+		s, _, _, err = readForm(s, pos, "expected a form to discard after #_")
+		return s, list, positions, err
+	}
+	if name, ok := readerMacros[token]; ok {
+		var form interfaces.Type
+		var formPos common.Pos
+		s, form, formPos, err = readForm(s, pos, "expected a form after "+token)
+		if err != nil {
+			return s, nil, nil, err
+		}
+		exp := &common.EXP{Function: common.REF(name), Arguments: []interfaces.Type{form}, Pos: pos, Positions: []common.Pos{pos, formPos}}
+		return s, append(list, exp), append(positions, pos), nil
+	}
 	if token == "(" {
 		var exp *common.EXP
-		s, exp, err = parseExpression(s)
+		s, exp, err = parseExpression(s, pos)
 		if err != nil {
-			return s, nil, err
+			return s, nil, nil, err
 		}
-		return s, append(list, exp), nil
+		return s, append(list, exp), append(positions, pos), nil
+	}
+	if token == "{" {
+		var exp *common.EXP
+		s, exp, err = parseMap(s, pos)
+		if err != nil {
+			return s, nil, nil, err
+		}
+		return s, append(list, exp), append(positions, pos), nil
 	}
 	if token == "[" {
 		var vec *common.VEC
-		s, vec, err = parseVector(s)
+		s, vec, err = parseVector(s, pos)
 		if err != nil {
-			return s, nil, err
+			return s, nil, nil, err
 		}
-		return s, append(list, *vec), nil
+		return s, append(list, *vec), append(positions, pos), nil
 	}
 	if len(token) > 0 {
 		t, err := parseTokenToType(token)
 		if err != nil {
-			return s, nil, err
+			return s, nil, nil, &common.SourceError{Pos: pos, Err: err}
 		}
 		list = append(list, t)
+		positions = append(positions, pos)
+	}
+	return s, list, positions, nil
+}
+
+// readForm parses the form following a token such as #_ or ', failing with message if there is none. A form that is
+// itself discarded does not count, so #_ #_ discards the two forms that follow.
+func readForm(s *scanner, pos common.Pos, message string) (*scanner, interfaces.Type, common.Pos, error) {
+	forms := []interfaces.Type{}
+	positions := []common.Pos{}
+	for len(forms) == 0 {
+		more, err := s.next()
+		if err != nil {
+			return s, nil, pos, err
+		}
+		token := s.Text()
+		if !more || isClosing(token) {
+			return s, nil, pos, s.errorAt(pos, message)
+		}
+		if s, forms, positions, err = addElementToArray(s, forms, positions, token); err != nil {
+			return s, nil, pos, err
+		}
 	}
-	return s, list, nil
+	return s, forms[0], positions[0], nil
+}
+
+func isClosing(token string) bool {
+	return token == ")" || token == "]" || token == "}"
 }
 
 func parseTokenToType(token string) (interfaces.Type, error) {
@@ -2,18 +2,31 @@ package parser
 
 import (
 	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
+// parseEXP parses input that is expected to hold a single EXP
+func parseEXP(t *testing.T, input string) (*common.EXP, error) {
+	forms, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	assert.Len(t, forms, 1)
+	exp, _ := forms[0].(*common.EXP)
+	return exp, nil
+}
+
 func Test_Parser_ExpressionFunctionName(t *testing.T) {
-	result, err := Parse("(+ 1 2)")
+	result, err := parseEXP(t, "(+ 1 2)")
 	assert.NoError(t, err)
 	assert.Equal(t, result.Function, common.REF("+"))
 }
 
 func Test_Parser_ExpressionArguments(t *testing.T) {
-	result, err := Parse("(+ 1 3)")
+	result, err := parseEXP(t, "(+ 1 3)")
 	assert.NoError(t, err)
 	assert.Equal(t, result.Arguments[0].(common.I).Int(), 1, "1st element")
 	assert.Equal(t, result.Arguments[1].(common.I).Int(), 3, "2nd element")
@@ -22,11 +35,11 @@ func Test_Parser_ExpressionArguments(t *testing.T) {
 
 func Test_Parser_ErrorWhenNoClosingBrackets(t *testing.T) {
 	_, err := Parse("(+ 1")
-	assert.EqualError(t, err, "Unexpected EOF while parsing EXP")
+	assert.EqualError(t, err, "1:1: Unexpected EOF while parsing EXP")
 }
 
 func Test_Parser_NestedExpression(t *testing.T) {
-	result, err := Parse("(+ 1 (+ 1 3))")
+	result, err := parseEXP(t, "(+ 1 (+ 1 3))")
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, 1, args[0].(common.I).Int(), "1st element")
@@ -35,14 +48,14 @@ func Test_Parser_NestedExpression(t *testing.T) {
 }
 
 func Test_Parser_REF(t *testing.T) {
-	result, err := Parse("(+ ref)")
+	result, err := parseEXP(t, "(+ ref)")
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0].(common.REF).String(), "ref")
 }
 
 func Test_Parser_VectorContainingIntegers(t *testing.T) {
-	result, err := Parse("(+ [1 2])")
+	result, err := parseEXP(t, "(+ [1 2])")
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0].(common.VEC).Get(0), common.I(1))
@@ -50,7 +63,7 @@ func Test_Parser_VectorContainingIntegers(t *testing.T) {
 }
 
 func Test_Parser_VectorContainingStrings(t *testing.T) {
-	result, err := Parse(`(+ ["hello" "world"])`)
+	result, err := parseEXP(t, `(+ ["hello" "world"])`)
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0].(common.VEC).Get(0), common.S("hello"))
@@ -58,14 +71,14 @@ func Test_Parser_VectorContainingStrings(t *testing.T) {
 }
 
 func Test_Parser_VectorWithSymbolsInside(t *testing.T) {
-	result, err := Parse("(fn [a])")
+	result, err := parseEXP(t, "(fn [a])")
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0].(common.VEC).Get(0), common.REF("a"))
 }
 
 func Test_Parser_QuotedTextAsStrings(t *testing.T) {
-	result, err := Parse(`(+ "hello" "world")`)
+	result, err := parseEXP(t, `(+ "hello" "world")`)
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0], common.S("hello"))
@@ -73,7 +86,7 @@ func Test_Parser_QuotedTextAsStrings(t *testing.T) {
 }
 
 func Test_Parser_FunctionNameWithDashes(t *testing.T) {
-	result, err := Parse(`(a-function 1 2)`)
+	result, err := parseEXP(t, `(a-function 1 2)`)
 	assert.NoError(t, err)
 	assert.Equal(t, common.REF("a-function"), result.Function)
 	args := result.Arguments
@@ -82,7 +95,7 @@ func Test_Parser_FunctionNameWithDashes(t *testing.T) {
 }
 
 func Test_Parser_Booleans(t *testing.T) {
-	result, err := Parse(`(= true false)`)
+	result, err := parseEXP(t, `(= true false)`)
 	assert.NoError(t, err)
 	assert.Equal(t, common.REF("="), result.Function)
 	args := result.Arguments
@@ -91,14 +104,14 @@ func Test_Parser_Booleans(t *testing.T) {
 }
 
 func Test_Parser_QuotedTextWithEscapedCharacters(t *testing.T) {
-	result, err := Parse(`(+ "hi \"there\"")`)
+	result, err := parseEXP(t, `(+ "hi \"there\"")`)
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0], common.S(`hi "there"`))
 }
 
 func Test_Parser_Floats(t *testing.T) {
-	result, err := Parse(`(+ 1.01 2.02)`)
+	result, err := parseEXP(t, `(+ 1.01 2.02)`)
 	assert.NoError(t, err)
 	args := result.Arguments
 	assert.Equal(t, args[0], common.F(1.01))
@@ -106,9 +119,165 @@ func Test_Parser_Floats(t *testing.T) {
 }
 
 func Test_Parser_Symbol(t *testing.T) {
-	result, err := Parse(`(:key :value)`)
+	result, err := parseEXP(t, `(:key :value)`)
 	assert.NoError(t, err)
 	assert.Equal(t, result.Function, common.SYM(":key"))
 	args := result.Arguments
 	assert.Equal(t, args[0], common.SYM(":value"))
 }
+
+func Test_Parser_ExpressionPositions(t *testing.T) {
+	result, err := parseEXP(t, "(+ 1\n  (- 2 x))")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Pos.Line)
+	assert.Equal(t, 1, result.Pos.Column)
+	assert.Equal(t, 1, result.Positions[0].Line)
+	assert.Equal(t, 2, result.Positions[0].Column)
+	nested := result.Arguments[1].(*common.EXP)
+	assert.Equal(t, "2:3", nested.Pos.String())
+	assert.Equal(t, "2:8", nested.Positions[2].String())
+}
+
+func Test_Parser_VectorPositions(t *testing.T) {
+	result, err := parseEXP(t, "(fn [a  b] a)")
+	assert.NoError(t, err)
+	vec := result.Arguments[0].(common.VEC)
+	assert.Equal(t, "1:5", vec.Pos.String())
+	assert.Equal(t, "1:6", vec.Positions[0].String())
+	assert.Equal(t, "1:9", vec.Positions[1].String())
+}
+
+func Test_Parser_ErrorWhenNoClosingVector(t *testing.T) {
+	_, err := Parse("(fn\n [a b")
+	assert.EqualError(t, err, "2:2: Unexpected EOF while parsing VEC")
+}
+
+func Test_Parser_ErrorWhenStringNotClosed(t *testing.T) {
+	_, err := Parse(`(print "hello)`)
+	assert.EqualError(t, err, "1:8: string not closed")
+}
+
+func Test_Parser_TopLevelLiteral(t *testing.T) {
+	forms, err := Parse("  42")
+	assert.NoError(t, err)
+	assert.Equal(t, []interfaces.Type{common.I(42)}, forms)
+}
+
+func Test_Parser_MultipleTopLevelForms(t *testing.T) {
+	forms, err := Parse("(def a 1)\n[a] :key \"s\" (+ a 1)")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(forms))
+	assert.Equal(t, common.REF("def"), forms[0].(*common.EXP).Function)
+	assert.Equal(t, []interfaces.Type{common.REF("a")}, forms[1].(common.VEC).Vector)
+	assert.Equal(t, common.SYM(":key"), forms[2])
+	assert.Equal(t, common.S("s"), forms[3])
+	assert.Equal(t, common.Pos{Source: forms[4].(*common.EXP).Pos.Source, Line: 2, Column: 14}, forms[4].(*common.EXP).Pos)
+}
+
+func Test_Parser_PositionsOfTopLevelForms(t *testing.T) {
+	forms, positions, err := ParsePositions("missing\n  42 (+ 1 2)")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(forms))
+	assert.Equal(t, []string{"1:1", "2:3", "2:6"}, []string{positions[0].String(), positions[1].String(), positions[2].String()})
+}
+
+func Test_Parser_NoForms(t *testing.T) {
+	forms, err := Parse(" ; nothing here\n")
+	assert.NoError(t, err)
+	assert.Empty(t, forms)
+}
+
+func Test_Parser_ErrorWhenFormIsNotOpened(t *testing.T) {
+	_, err := Parse("(+ 1 2))")
+	assert.EqualError(t, err, "1:8: unexpected )")
+	_, err = Parse("(+ 1 2)\n  ]")
+	assert.EqualError(t, err, "2:3: unexpected ]")
+}
+
+func Test_Parser_IgnoresComments(t *testing.T) {
+	result, err := parseEXP(t, "; adds numbers\n(+ 1 ; the first\n 2 [3 ; within a vector ]\n 4])")
+	assert.NoError(t, err)
+	assert.Equal(t, common.REF("+"), result.Function)
+	assert.Equal(t, 3, len(result.Arguments))
+	assert.Equal(t, []interfaces.Type{common.I(3), common.I(4)}, result.Arguments[2].(common.VEC).Vector)
+	assert.Equal(t, common.Pos{Source: result.Pos.Source, Line: 3, Column: 2}, result.Positions[2])
+}
+
+func Test_Parser_DiscardsForm(t *testing.T) {
+	result, err := parseEXP(t, "#_(ignored) (+ #_(* 2 3) 1 #_[a b] #_ #_ 2 3 4)")
+	assert.NoError(t, err)
+	assert.Equal(t, common.REF("+"), result.Function)
+	assert.Equal(t, []interfaces.Type{common.I(1), common.I(4)}, result.Arguments)
+}
+
+func Test_Parser_ErrorWhenNothingToDiscard(t *testing.T) {
+	_, err := Parse("(+ 1 #_)")
+	assert.EqualError(t, err, "1:6: expected a form to discard after #_")
+}
+
+func Test_Parser_ReaderMacrosWrapForm(t *testing.T) {
+	result, err := parseEXP(t, "(list 'a `(b ~c ~@d) @e)")
+	assert.NoError(t, err)
+	quoted := result.Arguments[0].(*common.EXP)
+	assert.Equal(t, common.REF("quote"), quoted.Function)
+	assert.Equal(t, []interfaces.Type{common.REF("a")}, quoted.Arguments)
+	assert.Equal(t, common.Pos{Source: quoted.Pos.Source, Line: 1, Column: 8}, quoted.Positions[1])
+	syntaxQuoted := result.Arguments[1].(*common.EXP)
+	assert.Equal(t, common.REF("syntax-quote"), syntaxQuoted.Function)
+	template := syntaxQuoted.Arguments[0].(*common.EXP)
+	assert.Equal(t, common.REF("unquote"), template.Arguments[0].(*common.EXP).Function)
+	assert.Equal(t, common.REF("unquote-splicing"), template.Arguments[1].(*common.EXP).Function)
+	assert.Equal(t, []interfaces.Type{common.REF("d")}, template.Arguments[1].(*common.EXP).Arguments)
+	assert.Equal(t, common.REF("deref"), result.Arguments[2].(*common.EXP).Function)
+}
+
+func Test_Parser_ErrorWhenNothingToQuote(t *testing.T) {
+	_, err := Parse("(list 1 ')")
+	assert.EqualError(t, err, "1:9: expected a form after '")
+}
+
+func Test_Parser_ErrorWhenEXPHasNoFunction(t *testing.T) {
+	_, err := Parse("(#_ a)")
+	assert.EqualError(t, err, "1:1: EXP has no function")
+}
+
+func Test_Incomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"(+ 1 2)", false},
+		{"(+ 1\n  (* 2", true},
+		{"(let [a 1", true},
+		{"(print \"a (string", true},
+		{"(print \"a \\\" (string\")", false},
+		{"(+ 1 2))", false},
+		{"", false},
+		{"(+ 1 ; (\n", true},
+		{"(+ 1 ; (\n 2)", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.incomplete, Incomplete(test.input), test.input)
+	}
+}
+
+func Test_Parser_CommentLongerThanBuffer(t *testing.T) {
+	result, err := parseEXP(t, "(+ 1 ;"+strings.Repeat("(", 10000)+"\n 2)")
+	assert.NoError(t, err)
+	assert.Equal(t, []interfaces.Type{common.I(1), common.I(2)}, result.Arguments)
+}
+
+func Test_Parser_MapBecomesHashMap(t *testing.T) {
+	result, err := parseEXP(t, "(get :a {:a [1 2] :b 3})")
+	assert.NoError(t, err)
+	mp := result.Arguments[1].(*common.EXP)
+	assert.Equal(t, common.REF("hash-map"), mp.Function)
+	assert.Equal(t, common.SYM(":a"), mp.Arguments[0])
+	assert.Equal(t, []interfaces.Type{common.I(1), common.I(2)}, mp.Arguments[1].(common.VEC).Vector)
+	assert.Equal(t, common.Pos{Source: mp.Pos.Source, Line: 1, Column: 9}, mp.Pos)
+}
+
+func Test_Parser_ErrorWhenMapHasOddNumberOfForms(t *testing.T) {
+	_, err := Parse("(get :a {:a 1 :b})")
+	assert.EqualError(t, err, "1:9: expected an even number of forms in map")
+}
@@ -2,6 +2,7 @@ package prelude
 
 import (
 	"fmt"
+	"github.com/mikeyhu/glipso/common"
 	"github.com/mikeyhu/glipso/interfaces"
 	"github.com/mikeyhu/glipso/parser"
 )
@@ -9,29 +10,30 @@ import (
 // ParsePrelude loads a number of definitions such as functions into global scope
 func ParsePrelude(scope interfaces.Scope) {
 	code := `
-	(do
-		(def defmacro (macro [n a e] (def n (macro a e))))
-		(defmacro defn [nn aa ee] (def nn (fn aa ee)))
+	(def defmacro (macro [name args body]
+		(syntax-quote (def (unquote name) (macro (unquote args) (unquote body))))))
+	(defmacro defn [name & forms]
+		(syntax-quote (def (unquote name) (fn (unquote-splicing forms)))))
 
-		(defn last [list]
-			(if
-				(empty (tail list))
-				(first list)
-				(last (tail list))))
+	(defn last [list]
+		(if
+			(empty (tail list))
+			(first list)
+			(last (tail list))))
 
-		(defn repeat [item times]
-			(if
-				(> times 1)
-				(lazypair item (repeat item (- times 1)))
-				(cons item)))
-	)
+	(defn repeat [item times]
+		(if
+			(> times 1)
+			(lazypair item (repeat item (- times 1)))
+			(cons item)))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	if err != nil {
 		panic(fmt.Sprintf("Error parsing prelude, error %v", err))
 	}
-	_, err = exp.Evaluate(scope)
-	if err != nil {
-		panic(fmt.Sprintf("Error evaluating prelude, error %v", err))
+	for _, form := range forms {
+		if _, err = common.Evaluate(form, scope); err != nil {
+			panic(fmt.Sprintf("Error evaluating prelude, error %v", err))
+		}
 	}
 }
@@ -17,9 +17,9 @@ func Test_PreludeDefnDoesNotShareScope(t *testing.T) {
 		(add2 (add1 (add2 100)))
 	)
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, _ := exp.Evaluate(common.GlobalEnvironment)
+	result, _ := forms[0].(*common.EXP).Evaluate(common.GlobalEnvironment)
 	assert.Equal(t, common.I(105), result)
 }
 
@@ -36,9 +36,9 @@ func Test_LastReturnsLastNumberInList(t *testing.T) {
 	code := `
 	(last (range 1 5))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, _ := exp.Evaluate(common.GlobalEnvironment)
+	result, _ := forms[0].(*common.EXP).Evaluate(common.GlobalEnvironment)
 	assert.Equal(t, common.I(5), result)
 }
 
@@ -47,9 +47,9 @@ func Test_RepeatReturnsTheItem(t *testing.T) {
 	code := `
 	(first (repeat "s" 5))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, _ := exp.Evaluate(common.GlobalEnvironment)
+	result, _ := forms[0].(*common.EXP).Evaluate(common.GlobalEnvironment)
 	assert.Equal(t, common.S("s"), result)
 }
 
@@ -58,8 +58,8 @@ func Test_RepeatReturnsTheItemNTimes(t *testing.T) {
 	code := `
 	(apply + (repeat 10 5))
 	`
-	exp, err := parser.Parse(code)
+	forms, err := parser.Parse(code)
 	assert.NoError(t, err)
-	result, _ := exp.Evaluate(common.GlobalEnvironment)
+	result, _ := forms[0].(*common.EXP).Evaluate(common.GlobalEnvironment)
 	assert.Equal(t, common.I(50), result)
 }
@@ -0,0 +1,177 @@
+// Package repl provides an interactive read eval print loop over an Interpreter
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/mikeyhu/glipso/interpreter"
+	"github.com/mikeyhu/glipso/parser"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt         = "glipso> "
+	continuePrompt = "     .. "
+)
+
+// REPL reads code from in, a line at a time, evaluating each complete input with its Interpreter and printing the
+// result to out. Inputs are appended to the history, if there is one, and listed by :history along with those of
+// earlier sessions.
+type REPL struct {
+	interp  *interpreter.Interpreter
+	in      *bufio.Scanner
+	out     io.Writer
+	history io.Writer
+	inputs  []string
+}
+
+// New creates a REPL that evaluates code read from in with interp, writing to out
+func New(interp *interpreter.Interpreter, in io.Reader, out io.Writer) *REPL {
+	return &REPL{interp: interp, in: bufio.NewScanner(in), out: out}
+}
+
+// WithHistory reads the inputs of earlier sessions from history, then appends each new input to it
+func (r *REPL) WithHistory(history io.ReadWriter) error {
+	inputs, err := readInputs(bufio.NewScanner(history))
+	if err != nil {
+		return err
+	}
+	r.inputs = append(inputs, r.inputs...)
+	r.history = history
+	return nil
+}
+
+// OpenHistory opens the file at path, creating it if needed, for the history to be read and appended to
+func OpenHistory(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+}
+
+// readInputs splits the lines read by in into inputs, joining lines until they form a complete input
+func readInputs(in *bufio.Scanner) ([]string, error) {
+	var inputs []string
+	var lines []string
+	for in.Scan() {
+		lines = append(lines, in.Text())
+		if input := strings.Join(lines, "\n"); !parser.Incomplete(input) {
+			inputs = append(inputs, input)
+			lines = nil
+		}
+	}
+	return inputs, in.Err()
+}
+
+// Run reads and evaluates input until :quit is entered or in is exhausted. Errors evaluating input are printed
+// rather than returned, only failing to read or write ends the loop early.
+func (r *REPL) Run() error {
+	for {
+		input, ok := r.read()
+		if !ok {
+			fmt.Fprintln(r.out)
+			return r.in.Err()
+		}
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		r.inputs = append(r.inputs, input)
+		if r.history != nil {
+			if _, err := fmt.Fprintln(r.history, input); err != nil {
+				return err
+			}
+		}
+		if quit := r.command(strings.TrimSpace(input)); quit {
+			return nil
+		}
+	}
+}
+
+// read reads lines until they form a complete input, returning false if in is exhausted first
+func (r *REPL) read() (string, bool) {
+	fmt.Fprint(r.out, prompt)
+	var lines []string
+	for r.in.Scan() {
+		lines = append(lines, r.in.Text())
+		input := strings.Join(lines, "\n")
+		if !parser.Incomplete(input) {
+			return input, true
+		}
+		fmt.Fprint(r.out, continuePrompt)
+	}
+	return "", false
+}
+
+// command runs one of the REPL commands, which begin with ':', or else evaluates input. It returns true to quit.
+func (r *REPL) command(input string) bool {
+	name, arg, _ := strings.Cut(input, " ")
+	switch name {
+	case ":quit":
+		return true
+	case ":env":
+		r.printEnvironment()
+	case ":load":
+		r.load(strings.TrimSpace(arg))
+	case ":expand":
+		r.expand(arg)
+	case ":history":
+		r.printHistory()
+	default:
+		if strings.HasPrefix(name, ":") {
+			fmt.Fprintf(r.out, "unknown command %v, expected :quit, :env, :history, :load file or :expand code\n", name)
+			return false
+		}
+		r.print(r.interp.Eval(input))
+	}
+	return false
+}
+
+func (r *REPL) load(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		r.print(nil, err)
+		return
+	}
+	defer file.Close()
+	r.print(r.interp.EvalFile(file))
+}
+
+func (r *REPL) expand(input string) {
+	forms, err := r.interp.Expand(input)
+	if err != nil {
+		r.print(nil, err)
+		return
+	}
+	for _, form := range forms {
+		fmt.Fprintln(r.out, common.Sprint(form, r.interp.Environment()))
+	}
+}
+
+func (r *REPL) print(result interfaces.Value, err error) {
+	if err != nil {
+		fmt.Fprintln(r.out, common.ErrorDetail(err))
+		return
+	}
+	fmt.Fprintln(r.out, common.Sprint(result, r.interp.Environment()))
+}
+
+func (r *REPL) printEnvironment() {
+	variables := r.interp.Environment().Variables()
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%v = %v\n", name, common.Sprint(variables[common.REF(name)], r.interp.Environment()))
+	}
+}
+
+// printHistory prints each input of this and earlier sessions, numbered from the oldest
+func (r *REPL) printHistory() {
+	for n, input := range r.inputs {
+		fmt.Fprintf(r.out, "%d: %v\n", n+1, input)
+	}
+}
@@ -0,0 +1,105 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/mikeyhu/glipso/interpreter"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, input string) string {
+	var out bytes.Buffer
+	err := New(interpreter.New(), strings.NewReader(input), &out).Run()
+	assert.NoError(t, err)
+	return out.String()
+}
+
+func Test_REPL_KeepsDefinitionsAcrossInputs(t *testing.T) {
+	//when
+	out := run(t, "(def a 2)\n(+ a 1)\n")
+
+	//then
+	assert.Equal(t, "glipso> nil\nglipso> 3\nglipso> \n", out)
+}
+
+func Test_REPL_ContinuesIncompleteInput(t *testing.T) {
+	//when
+	out := run(t, "(cons\n  \"a [b\"\n)\n")
+
+	//then
+	assert.Equal(t, "glipso>      ..      .. (\"a [b\")\nglipso> \n", out)
+}
+
+func Test_REPL_ReportsErrorsAndCarriesOn(t *testing.T) {
+	//when
+	out := run(t, "(+ 1 missing)\n(+ 1 2)\n:quit\n(+ 3 4)\n")
+
+	//then
+	assert.Equal(t, "glipso> 1:6: unable to resolve REF('missing')\n(+ 1 missing)\n     ^\n\tin (+ 1 missing) at 1:1\nglipso> 3\nglipso> ", out)
+}
+
+func Test_REPL_Commands(t *testing.T) {
+	//given
+	file := filepath.Join(t.TempDir(), "load.glipso")
+	assert.NoError(t, os.WriteFile(file, []byte("(def loaded [1 \"one\"])"), 0600))
+
+	//when
+	out := run(t, ":load "+file+"\n:env\n:unknown\n")
+
+	//then
+	assert.Contains(t, out, "glipso> nil\n")
+	assert.Contains(t, out, "loaded = [1 \"one\"]\n")
+	assert.Contains(t, out, "unknown command :unknown, expected :quit, :env, :history, :load file or :expand code\n")
+}
+
+func Test_REPL_ExpandsMacros(t *testing.T) {
+	//when
+	out := run(t, ":expand (defn inc [n] (+ n 1))\n(inc 1)\n")
+
+	//then
+	assert.Equal(t, "glipso> (def inc (fn [n] (+ n 1)))\nglipso> 1:2: evaluate : function 'inc' not found\n(inc 1)\n ^\nglipso> \n", out)
+}
+
+func Test_REPL_AppendsToHistory(t *testing.T) {
+	//given
+	path := filepath.Join(t.TempDir(), "history")
+	for _, input := range []string{"(+ 1\n 2)\n", "\n:quit\n"} {
+		history, err := OpenHistory(path)
+		assert.NoError(t, err)
+
+		//when
+		r := New(interpreter.New(), strings.NewReader(input), &bytes.Buffer{})
+		assert.NoError(t, r.WithHistory(history))
+		assert.NoError(t, r.Run())
+		assert.NoError(t, history.Close())
+	}
+
+	//then
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "(+ 1\n 2)\n:quit\n", string(contents))
+}
+
+func Test_REPL_ListsHistoryOfEarlierSessions(t *testing.T) {
+	//given
+	path := filepath.Join(t.TempDir(), "history")
+	assert.NoError(t, os.WriteFile(path, []byte("(+ 1\n 2)\n(def a 1)\n"), 0600))
+	history, err := OpenHistory(path)
+	assert.NoError(t, err)
+	defer history.Close()
+	var out bytes.Buffer
+	r := New(interpreter.New(), strings.NewReader("(+ a 1)\n:history\n"), &out)
+
+	//when
+	assert.NoError(t, r.WithHistory(history))
+	assert.NoError(t, r.Run())
+
+	//then
+	assert.Contains(t, out.String(), "1: (+ 1\n 2)\n2: (def a 1)\n3: (+ a 1)\n4: :history\n")
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "(+ 1\n 2)\n(def a 1)\n(+ a 1)\n:history\n", string(contents))
+}
@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/compiler"
+	"github.com/mikeyhu/glipso/interfaces"
+)
+
+// Closure is a compiled function along with the values it captured when it was created
+type Closure struct {
+	proto    *compiler.Proto
+	upvalues []interfaces.Value
+	scope    interfaces.Scope
+}
+
+// IsType for Closure
+func (c *Closure) IsType() {}
+
+// IsValue for Closure
+func (c *Closure) IsValue() {}
+
+// String representation of Closure
+func (c *Closure) String() string {
+	return fmt.Sprintf("FN(%s)", c.proto.Name)
+}
+
+// Apply for Closure : evaluates the arguments in the scope provided and then runs the compiled function
+func (c *Closure) Apply(arguments []interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	sco = common.Evaluating(sco)
+	values := make([]interfaces.Value, len(arguments))
+	for i, arg := range arguments {
+		var err error
+		if values[i], err = evaluateToValue(arg, sco); err != nil {
+			return common.NILL, err
+		}
+	}
+	return execute(c, values, common.EvaluationOf(sco))
+}
+
+// thunk is the tail of a lazypair, running the compiled expression when the pair is iterated
+type thunk struct {
+	closure Closure
+}
+
+// Evaluate runs the compiled tail under the Evaluation of the scope provided, otherwise ignoring it as the closure has
+// captured what it needs
+func (t *thunk) Evaluate(sco interfaces.Scope) (interfaces.Value, error) {
+	return execute(&t.closure, nil, common.EvaluationOf(common.Evaluating(sco)))
+}
+
+// String representation of thunk
+func (t *thunk) String() string {
+	return t.closure.String()
+}
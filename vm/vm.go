@@ -0,0 +1,415 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/compiler"
+	"github.com/mikeyhu/glipso/interfaces"
+	"sync"
+)
+
+// Evaluate compiles code and runs it against scope
+func Evaluate(code interfaces.Type, scope interfaces.Scope) (interfaces.Value, error) {
+	scope = common.Evaluating(scope)
+	proto, err := compiler.Compile(code, scope)
+	if err != nil {
+		return common.NILL, err
+	}
+	return Run(proto, scope)
+}
+
+// EvaluateContext is Evaluate, stopping once ctx is cancelled or its deadline passes. The error returned is then an
+// EvalError wrapping context.Canceled or context.DeadlineExceeded.
+func EvaluateContext(ctx context.Context, code interfaces.Type, scope interfaces.Scope) (interfaces.Value, error) {
+	scope, release := common.WithContext(ctx, scope)
+	defer release()
+	return Evaluate(code, scope)
+}
+
+// Run executes a Proto compiled by compiler.Compile, resolving globals against scope
+func Run(proto *compiler.Proto, scope interfaces.Scope) (interfaces.Value, error) {
+	scope = common.Evaluating(scope)
+	return execute(&Closure{proto: proto, scope: scope}, nil, common.EvaluationOf(scope))
+}
+
+type frame struct {
+	closure *Closure
+	ip      int
+	base    int
+}
+
+// machine holds the stack of a single run of the vm. Compiled functions applied by compiled code share the machine,
+// anything applied from outside, such as a Closure applied by an inbuilt function, gets a new one.
+type machine struct {
+	scope      interfaces.Scope
+	evaluation *common.Evaluation
+	stack      []interfaces.Value
+	frames     []frame
+}
+
+var machines = sync.Pool{
+	New: func() interface{} {
+		return &machine{
+			stack:  make([]interfaces.Value, 0, 16),
+			frames: make([]frame, 0, 8),
+		}
+	},
+}
+
+// execute applies a Closure to evaluated arguments on a machine taken from the pool, under evaluation, which is that of
+// whatever applied the Closure rather than that of the scope it was created in
+func execute(closure *Closure, args []interfaces.Value, evaluation *common.Evaluation) (interfaces.Value, error) {
+	m := machines.Get().(*machine)
+	m.scope = common.Within(closure.scope, evaluation)
+	m.evaluation = evaluation
+	result, err := m.call(closure, args)
+	m.evaluation.Leave(len(m.frames))
+	m.reset()
+	machines.Put(m)
+	return result, err
+}
+
+// reset clears the machine so that the values it held can be collected
+func (m *machine) reset() {
+	clear(m.stack[:cap(m.stack)])
+	m.stack = m.stack[:0]
+	m.frames = m.frames[:0]
+	m.scope = nil
+	m.evaluation = nil
+}
+
+func (m *machine) push(v interfaces.Value) {
+	m.stack = append(m.stack, v)
+}
+
+func (m *machine) pop() interfaces.Value {
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return v
+}
+
+func (m *machine) call(closure *Closure, args []interfaces.Value) (interfaces.Value, error) {
+	m.push(closure)
+	m.stack = append(m.stack, args...)
+	if err := m.enter(closure, len(args), false); err != nil {
+		return common.NILL, err
+	}
+	return m.run()
+}
+
+// enter starts a new frame for a Closure with its arguments on top of the stack, or replaces the current frame
+// when tail is true
+func (m *machine) enter(closure *Closure, n int, tail bool) error {
+	proto := closure.proto
+	if n < proto.Arity {
+		return errors.New("too few arguments")
+	} else if proto.Variadic {
+		rest := len(m.stack) - n + proto.Arity
+		m.evaluation.Allocate(n - proto.Arity)
+		list := common.NewList(m.stack[rest:])
+		m.stack = m.stack[:rest]
+		m.push(list)
+		n = proto.Arity + 1
+	} else if n > proto.Arity {
+		return errors.New("too many arguments")
+	}
+	m.evaluation.AllocateScope()
+	base := len(m.stack) - n
+	if tail {
+		current := &m.frames[len(m.frames)-1]
+		copy(m.stack[current.base-1:], m.stack[base-1:])
+		base = current.base
+		m.stack = m.stack[:base+n]
+		current.closure = closure
+		current.ip = 0
+	} else {
+		if err := m.evaluation.Enter(); err != nil {
+			return err
+		}
+		m.frames = append(m.frames, frame{closure: closure, base: base})
+	}
+	for i := n; i < proto.Locals; i++ {
+		m.push(nil)
+	}
+	return nil
+}
+
+func (m *machine) run() (interfaces.Value, error) {
+	fr := &m.frames[len(m.frames)-1]
+	proto := fr.closure.proto
+	for {
+		ins := proto.Code[fr.ip]
+		fr.ip++
+		arg := ins.Arg()
+		switch ins.Op() {
+		case compiler.OpConst:
+			m.push(proto.Constants[arg])
+		case compiler.OpNil:
+			m.push(common.NILL)
+		case compiler.OpLocal:
+			m.push(m.stack[fr.base+arg])
+		case compiler.OpSetLocal:
+			m.stack[fr.base+arg] = m.pop()
+		case compiler.OpUpvalue:
+			m.push(fr.closure.upvalues[arg])
+		case compiler.OpGlobal, compiler.OpFunction:
+			name := proto.Names[arg]
+			value, ok := m.scope.ResolveRef(name)
+			if !ok {
+				if ins.Op() == compiler.OpFunction {
+					return common.NILL, m.fail(fmt.Errorf("evaluate : function '%v' not found", name), nil, 0)
+				}
+				return common.NILL, m.fail(fmt.Errorf("unable to resolve REF('%v')", name), nil, 0)
+			}
+			m.push(value)
+		case compiler.OpPop:
+			m.pop()
+		case compiler.OpJump:
+			if arg < fr.ip {
+				if err := m.evaluation.Step(); err != nil {
+					return common.NILL, m.fail(err, nil, 0)
+				}
+			}
+			fr.ip = arg
+		case compiler.OpJumpIfFalse:
+			test := m.pop()
+			b, ok := test.(common.B)
+			if !ok {
+				return common.NILL, m.fail(fmt.Errorf("if : expected first argument to evaluate to boolean, recieved %v", test), nil, 0)
+			}
+			if !b {
+				fr.ip = arg
+			}
+		case compiler.OpGuard:
+			function := m.stack[len(m.stack)-1]
+			if deferred(function) {
+				m.pop()
+				fb := proto.Fallbacks[arg]
+				result, err := m.fallback(fr, fb, function)
+				if err != nil {
+					return common.NILL, m.fail(err, nil, 0)
+				}
+				m.push(result)
+				fr.ip = fb.Resume
+			}
+		case compiler.OpCall, compiler.OpTailCall:
+			if err := m.invoke(arg, ins.Op() == compiler.OpTailCall); err != nil {
+				return common.NILL, err
+			}
+			fr = &m.frames[len(m.frames)-1]
+			proto = fr.closure.proto
+		case compiler.OpApply, compiler.OpTailApply:
+			n, err := m.spread(m.pop())
+			if err != nil {
+				return common.NILL, m.fail(err, nil, 0)
+			}
+			if err := m.invoke(n+arg, ins.Op() == compiler.OpTailApply); err != nil {
+				return common.NILL, err
+			}
+			fr = &m.frames[len(m.frames)-1]
+			proto = fr.closure.proto
+		case compiler.OpReturn:
+			result := m.pop()
+			m.stack = m.stack[:fr.base-1]
+			m.frames = m.frames[:len(m.frames)-1]
+			m.evaluation.Leave(1)
+			if len(m.frames) == 0 {
+				return result, nil
+			}
+			m.push(result)
+			fr = &m.frames[len(m.frames)-1]
+			proto = fr.closure.proto
+		case compiler.OpClosure:
+			m.push(m.closure(fr, proto.Protos[arg]))
+		case compiler.OpLazyPair:
+			var tail interfaces.Evaluatable
+			if arg > 0 {
+				tail = &thunk{*m.closure(fr, proto.Protos[arg-1])}
+			}
+			m.evaluation.Allocate(1)
+			m.push(common.NewLAZYP(m.pop(), tail))
+		case compiler.OpDef:
+			common.GlobalOf(m.scope).CreateRef(proto.Names[arg], m.pop())
+			m.push(common.NILL)
+		case compiler.OpEval:
+			result, err := m.fallback(fr, proto.Fallbacks[arg], nil)
+			if err != nil {
+				return common.NILL, m.fail(err, nil, 0)
+			}
+			m.push(result)
+		default:
+			return common.NILL, m.fail(fmt.Errorf("vm : unknown instruction %v", ins), nil, 0)
+		}
+	}
+}
+
+// spread pushes the items of a list onto the stack for apply, returning how many there were. A LAZYP is iterated
+// directly rather than converted to a slice first.
+func (m *machine) spread(list interfaces.Value) (int, error) {
+	if lazy, ok := list.(common.LAZYP); ok {
+		n := 0
+		var next interfaces.Iterable = lazy
+		for {
+			m.push(next.Head())
+			n++
+			if !next.HasTail() {
+				return n, nil
+			}
+			var err error
+			if next, err = next.Iterate(m.scope); err != nil {
+				return n, err
+			}
+		}
+	}
+	sliceable, ok := list.(interfaces.Sliceable)
+	if !ok {
+		return 0, fmt.Errorf("apply : expected pair, found %v", list)
+	}
+	items, err := sliceable.ToSlice(m.scope)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		value, err := evaluateToValue(item, m.scope)
+		if err != nil {
+			return 0, err
+		}
+		m.push(value)
+	}
+	return len(items), nil
+}
+
+// invoke applies the function below n arguments on the stack. Closures are entered, other functions are applied
+// straight away and their result replaces them on the stack.
+func (m *machine) invoke(n int, tail bool) error {
+	if err := m.evaluation.Step(); err != nil {
+		return m.fail(err, nil, 0)
+	}
+	function := m.stack[len(m.stack)-n-1]
+	if closure, ok := function.(*Closure); ok {
+		if err := m.enter(closure, n, tail); err != nil {
+			return m.fail(err, function, n)
+		}
+		return nil
+	}
+	result, err := m.apply(function, m.stack[len(m.stack)-n:len(m.stack):len(m.stack)])
+	if err != nil {
+		return m.fail(err, function, n)
+	}
+	m.stack = m.stack[:len(m.stack)-n-1]
+	m.push(result)
+	return nil
+}
+
+// apply applies anything other than a Closure to evaluated arguments. The arguments are still on the stack, inbuilt
+// functions are given them directly as they do not retain them.
+func (m *machine) apply(function interfaces.Value, args []interfaces.Value) (interfaces.Value, error) {
+	if fi, ok := function.(common.FI); ok && !fi.Lazy() {
+		return fi.ApplyValues(args, m.scope)
+	}
+	if deferred(function) {
+		return (&common.EXP{Function: function, Arguments: asTypes(args)}).Evaluate(m.scope)
+	}
+	if appliable, ok := function.(interfaces.Appliable); ok {
+		return appliable.Apply(asTypes(args), m.scope)
+	}
+	return common.NILL, fmt.Errorf("evaluate : '%v' is not a function", function)
+}
+
+// fallback evaluates code with the tree walking evaluator in an Environment holding the variables visible to it.
+// When a function is given it replaces the Function of the code, having already been evaluated by the guard.
+func (m *machine) fallback(fr *frame, fb *compiler.Fallback, function interfaces.Value) (interfaces.Value, error) {
+	env := m.scope.NewChildScope()
+	for i, name := range fb.Names {
+		env.CreateRef(name, m.lookup(fr, fb.Vars[i]))
+	}
+	code := fb.Code
+	if function != nil {
+		code = &common.EXP{Function: function, Arguments: code.Arguments, Pos: code.Pos, Positions: code.Positions}
+	}
+	return code.Evaluate(env)
+}
+
+// closure creates a Closure for a Proto, capturing its upvalues from the frame
+func (m *machine) closure(fr *frame, proto *compiler.Proto) *Closure {
+	upvalues := make([]interfaces.Value, len(proto.Upvalues))
+	for i, v := range proto.Upvalues {
+		upvalues[i] = m.lookup(fr, v)
+	}
+	return &Closure{proto: proto, upvalues: upvalues, scope: m.scope}
+}
+
+func (m *machine) lookup(fr *frame, v compiler.Var) interfaces.Value {
+	if v.Upvalue {
+		return fr.closure.upvalues[v.Index]
+	}
+	return m.stack[fr.base+v.Index]
+}
+
+// fail converts an error into an EvalError, positioned at the instruction that caused it, with a Frame for the
+// function that failed, applied to the top n values of the stack, and for each Closure being evaluated
+func (m *machine) fail(err error, function interfaces.Value, n int) error {
+	var evalErr *common.EvalError
+	if !errors.As(err, &evalErr) {
+		evalErr = &common.EvalError{Err: err}
+	}
+	args := m.stack[len(m.stack)-n:]
+	for i := len(m.frames) - 1; i >= 0; i-- {
+		fr := m.frames[i]
+		pos := fr.closure.proto.Positions[fr.ip-1]
+		if !evalErr.Pos.IsValid() {
+			evalErr.Pos = pos
+		}
+		if function != nil {
+			appliable, _ := function.(interfaces.Appliable)
+			evalErr.Frames = append(evalErr.Frames, common.Frame{
+				Name:      nameOf(function),
+				Arguments: common.SummariseArguments(asTypes(args)),
+				Pos:       pos,
+				Function:  appliable,
+			})
+		}
+		function = fr.closure
+		args = m.stack[fr.base : fr.base+fr.closure.proto.Arity]
+	}
+	return evalErr
+}
+
+func nameOf(function interfaces.Value) string {
+	switch f := function.(type) {
+	case *Closure:
+		return f.proto.Name
+	case common.FI:
+		return f.Name()
+	}
+	return function.String()
+}
+
+// deferred returns true for functions that must receive their arguments unevaluated
+func deferred(function interfaces.Value) bool {
+	if _, ok := function.(interfaces.Expandable); ok {
+		return true
+	}
+	fi, ok := function.(common.FI)
+	return ok && fi.Lazy()
+}
+
+func evaluateToValue(value interfaces.Type, sco interfaces.Scope) (interfaces.Value, error) {
+	switch v := value.(type) {
+	case interfaces.Evaluatable:
+		return v.Evaluate(sco)
+	case interfaces.Value:
+		return v, nil
+	}
+	return common.NILL, fmt.Errorf("evaluateToValue : value %v of type %v is neither evaluatable or a result", value, value)
+}
+
+func asTypes(values []interfaces.Value) []interfaces.Type {
+	types := make([]interfaces.Type, len(values))
+	for i, v := range values {
+		types[i] = v
+	}
+	return types
+}
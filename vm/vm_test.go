@@ -0,0 +1,237 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"github.com/mikeyhu/glipso/common"
+	"github.com/mikeyhu/glipso/interfaces"
+	"github.com/mikeyhu/glipso/parser"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func evaluate(t *testing.T, code string) (interfaces.Value, error) {
+	forms, err := parser.Parse(code)
+	assert.NoError(t, err)
+	var result interfaces.Value = common.NILL
+	for _, form := range forms {
+		if result, err = Evaluate(form, common.GlobalEnvironment); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func Test_VM_AppliesInbuiltFunctions(t *testing.T) {
+	//when
+	result, err := evaluate(t, "(+ 1 (* 2 3) (- 10 4))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(13), result)
+}
+
+func Test_VM_IfChoosesBranch(t *testing.T) {
+	//when
+	result, err := evaluate(t, `(if (< 2 1) "yes" "no")`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.S("no"), result)
+}
+
+func Test_VM_LetBindsSequentially(t *testing.T) {
+	//when
+	result, err := evaluate(t, "(let [a 1 b (+ a 1)] (let [a 10] (+ a b)))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(12), result)
+}
+
+func Test_VM_ClosureCapturesValues(t *testing.T) {
+	//when
+	result, err := evaluate(t, `
+	(do
+		(def vmadder (fn [a] (fn [b] (+ a b))))
+		(let [add2 (vmadder 2)] (add2 5)))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(7), result)
+}
+
+func Test_VM_ClosureAppliedByInbuiltFunction(t *testing.T) {
+	//when
+	result, err := evaluate(t, `(let [offset 3] (apply + (map (fn [x] (+ x offset)) (range 1 3))))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(15), result)
+}
+
+func Test_VM_NonTailRecursionUsesFramesRatherThanGoStack(t *testing.T) {
+	//when
+	result, err := evaluate(t, `
+	(do
+		(def vmsum (fn [n] (if (= n 0) 0 (+ n (vmsum (- n 1))))))
+		(vmsum 10000))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(50005000), result)
+}
+
+func Test_VM_TailCallsReuseFrame(t *testing.T) {
+	//when
+	result, err := evaluate(t, `
+	(do
+		(def vmcount (fn [n acc] (if (= n 0) acc (vmcount (- n 1) (+ acc 1)))))
+		(vmcount 100000 0))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(100000), result)
+}
+
+func Test_VM_LoopRecur(t *testing.T) {
+	//when
+	result, err := evaluate(t, "(loop [i 0 acc 1] (if (< i 5) (recur (+ i 1) (* acc 2)) acc))")
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(32), result)
+}
+
+func Test_VM_LazyPairTailIsEvaluatedWhenIterated(t *testing.T) {
+	//when
+	result, err := evaluate(t, `
+	(do
+		(def vmrange (fn [s e] (if (< s e) (lazypair s (vmrange (+ s 1) e)) (cons s))))
+		(apply + (vmrange 1 5)))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(15), result)
+}
+
+func Test_VM_MacroDefinedWhileRunningFallsBackWithLocals(t *testing.T) {
+	//when
+	result, err := evaluate(t, `
+	(let [x 10]
+		(do
+			(def vmaddx (macro [a] (syntax-quote (+ (unquote a) x))))
+			(vmaddx 1)))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(11), result)
+}
+
+func Test_VM_MalformedSpecialFormReportsTreeWalkingError(t *testing.T) {
+	//when
+	result, err := evaluate(t, "(if true 1)")
+
+	//then
+	assert.Equal(t, common.NILL, result)
+	assert.EqualError(t, err, "1:1: if : invalid number of arguments [2 of 3]")
+}
+
+func Test_VM_RecurOutsideLoopReportsError(t *testing.T) {
+	//when
+	_, err := evaluate(t, "(fn [a] (recur a))")
+
+	//then
+	assert.EqualError(t, err, "1:1: recur : can only be used within loop")
+}
+
+func Test_VM_UnresolvedREFReportsPosition(t *testing.T) {
+	//when
+	result, err := evaluate(t, "(+ 1\n   missing)")
+
+	//then
+	assert.Equal(t, common.NILL, result)
+	assert.EqualError(t, err, "2:4: unable to resolve REF('missing')")
+}
+
+func Test_VM_IfRequiresBoolean(t *testing.T) {
+	//when
+	_, err := evaluate(t, "(if 1 2 3)")
+
+	//then
+	assert.EqualError(t, err, "1:1: if : expected first argument to evaluate to boolean, recieved 1")
+}
+
+func Test_VM_ClosureArityIsChecked(t *testing.T) {
+	//when
+	_, err := evaluate(t, "((fn [a] a) 1 2)")
+
+	//then
+	assert.EqualError(t, err, "1:1: too many arguments")
+}
+
+func Test_VM_VariadicClosureReceivesRestAsList(t *testing.T) {
+	//when
+	result, err := evaluate(t, `
+	(do
+		(def vmsum (fn [a & rest] (if (empty rest) a (+ a (apply vmsum rest)))))
+		(+ (vmsum 1) (vmsum 1 2 3) (apply vmsum 1 2 (cons 3))))`)
+
+	//then
+	assert.NoError(t, err)
+	assert.Equal(t, common.I(13), result)
+}
+
+func Test_VM_ErrorRecordsFramesOfClosures(t *testing.T) {
+	//when
+	_, err := evaluate(t, `
+	(do
+		(def vmfails (fn [a] (+ a true)))
+		(+ 1 (vmfails 1)))`)
+
+	//then
+	evalErr, ok := err.(*common.EvalError)
+	assert.True(t, ok)
+	assert.Equal(t, "3:24: numericFlatten : expected Numeric but argument 2 was true", evalErr.Error())
+	assert.Equal(t, []string{"(+ 1 true) at 3:24", "(vmfails 1) at 4:8"}, []string{
+		evalErr.Frames[0].String(), evalErr.Frames[1].String(),
+	})
+}
+
+func Test_VM_EvaluateContextStopsLoop(t *testing.T) {
+	//given
+	forms, err := parser.Parse("(loop [i 0] (recur (+ i 1)))")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	//when
+	_, err = EvaluateContext(ctx, forms[0], common.NewEnvironment())
+
+	//then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.IsType(t, &common.EvalError{}, err)
+}
+
+func Test_VM_WrappedEvalErrorKeepsCollectingFrames(t *testing.T) {
+	//given
+	env := common.NewEnvironment()
+	env.AddInbuilt(common.NewFI("vmwraps", func(arguments []interfaces.Value, sco interfaces.Scope) (interfaces.Value, error) {
+		_, err := common.Evaluate(&common.EXP{Function: common.REF("first"), Arguments: []interfaces.Type{common.B(true)}}, sco)
+		return common.NILL, fmt.Errorf("vmwraps : %w", err)
+	}))
+	forms, err := parser.Parse("(+ 1 (vmwraps))")
+	assert.NoError(t, err)
+
+	//when
+	_, err = Evaluate(forms[0], env)
+
+	//then
+	evalErr, ok := err.(*common.EvalError)
+	assert.True(t, ok)
+	assert.Equal(t, "1:6: first : true is not of type Iterable", evalErr.Error())
+	assert.Equal(t, []string{"(first true)", "(vmwraps) at 1:6"}, []string{
+		evalErr.Frames[0].String(), evalErr.Frames[1].String(),
+	})
+}